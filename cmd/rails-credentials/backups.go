@@ -0,0 +1,305 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/jamesits/go-rails-credentials/pkg/credentials"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Backups of the encrypted files are kept per file in tmp/credentials-backups/<dir>/<timestamp>.yml.enc, where dir is
+// the environment for its default file, or derived from the path for any other file.
+// tmp/ is ignored by git in every Rails project, and the backups are as safe as the encrypted files themselves.
+var backupDir = filepath.Join("tmp", "credentials-backups")
+
+const (
+	// globalBackupName is the backup directory of config/credentials.yml.enc; Rails environments do not start with _
+	globalBackupName = "_global"
+	// fileBackupName holds the backup directories of files given with --credentials-file, by their escaped path
+	fileBackupName   = "_file"
+	backupTimeFormat = "20060102T150405.000000000Z"
+	backupExtension  = ".yml.enc"
+	latestBackupID   = "latest"
+
+	noBackupsTemplate     = "No backups of %s.\n"
+	backupChangesTemplate = "Changes from %s to %s:\n"
+	noChangesMessage      = "  (no changes)\n"
+	restorePrompt         = "Restore this backup? The current version is backed up first. [Y/n] "
+	restoredTemplate      = "Restored %s from backup %s.\n"
+)
+
+type Backups struct {
+	List    BackupsList    `cmd:"" help:"List the backups of the credentials, newest first"`
+	Show    BackupsShow    `cmd:"" help:"Show what changed between a backup and the current credentials"`
+	Restore BackupsRestore `cmd:"" help:"Replace the credentials with a backup"`
+}
+
+type BackupsList struct{}
+
+type BackupsShow struct {
+	ID      string `arg:"" name:"id" help:"The backup to show, as printed by 'backups list', or 'latest'."`
+	Content bool   `name:"content" help:"Print the decrypted backup instead of the changes."`
+}
+
+type BackupsRestore struct {
+	ID  string `arg:"" name:"id" help:"The backup to restore, as printed by 'backups list', or 'latest'."`
+	Yes bool   `name:"yes" short:"y" help:"Do not ask for confirmation."`
+}
+
+type backup struct {
+	ID   string
+	Path string
+	Time time.Time
+}
+
+// backupDirFor returns the backup directory of an encrypted file, so the backups of different files never mix, and
+// one file can not be restored over another.
+func backupDirFor(environment string, path string) string {
+	_, defaultFile := credentials.DefaultPaths(environment)
+	if filepath.Clean(path) != filepath.Clean(defaultFile) {
+		return filepath.Join(backupDir, fileBackupName, url.PathEscape(filepath.ToSlash(relativePath(path))))
+	}
+	if environment == "" {
+		return filepath.Join(backupDir, globalBackupName)
+	}
+	return filepath.Join(backupDir, environment)
+}
+
+// relativePath returns the path relative to the current directory if it is below it, so the same file given as an
+// absolute or a relative path has the same backups.
+func relativePath(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return filepath.Clean(path)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		return abs
+	}
+	rel, err := filepath.Rel(wd, abs)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return abs
+	}
+	return rel
+}
+
+// backupFile copies the current version of an encrypted file into its backups before it is overwritten, and deletes
+// the oldest backups beyond --keep-backups.
+func (cli *Cli) backupFile(environment string, path string) error {
+	if cli.KeepBackups <= 0 {
+		return nil
+	}
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("unable to back up %s: %w", path, err)
+	}
+
+	name := filepath.Join(backupDirFor(environment, path), time.Now().UTC().Format(backupTimeFormat)+backupExtension)
+	err = credentials.WriteFile(name, content, 0o600)
+	if err != nil {
+		return fmt.Errorf("unable to back up %s: %w", path, err)
+	}
+
+	backups, err := listBackups(environment, path)
+	if err != nil {
+		return err
+	}
+	for i := cli.KeepBackups; i < len(backups); i++ {
+		err = os.Remove(backups[i].Path)
+		if err != nil {
+			return fmt.Errorf("unable to delete old backup: %w", err)
+		}
+	}
+	return nil
+}
+
+// listBackups returns the backups of an encrypted file, newest first.
+func listBackups(environment string, path string) ([]backup, error) {
+	dir := backupDirFor(environment, path)
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("unable to list backups: %w", err)
+	}
+
+	var ret []backup
+	for _, e := range entries {
+		id, ok := strings.CutSuffix(e.Name(), backupExtension)
+		if !ok || !e.Type().IsRegular() {
+			continue
+		}
+		t, err := time.Parse(backupTimeFormat, id)
+		if err != nil {
+			continue
+		}
+		ret = append(ret, backup{ID: id, Path: filepath.Join(dir, e.Name()), Time: t})
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Time.After(ret[j].Time) })
+	return ret, nil
+}
+
+func findBackup(environment string, path string, id string) (backup, error) {
+	backups, err := listBackups(environment, path)
+	if err != nil {
+		return backup{}, err
+	}
+	for _, b := range backups {
+		if b.ID == id || (id == latestBackupID && b == backups[0]) {
+			return b, nil
+		}
+	}
+	return backup{}, fmt.Errorf("backup %s not found; see '%s'", id, executable("backups", "list"))
+}
+
+// decryptCredentials decrypts encrypted file content into the plaintext credentials.
+func decryptCredentials(masterKey string, encrypted []byte) (string, error) {
+	rawObject, err := credentials.Decrypt(masterKey, string(encrypted))
+	if err != nil {
+		return "", fmt.Errorf("decrypt failed: %w", err)
+	}
+	return credentials.UnmarshalSingleString(rawObject)
+}
+
+// readTree decrypts and parses an encrypted file; a missing file is an empty tree.
+func (cli *Cli) readTree(path string) (map[string]any, error) {
+	e, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return map[string]any{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	content, err := decryptCredentials(cli.MasterKey, e)
+	if err != nil {
+		return nil, err
+	}
+	return credentials.ParseContent(content)
+}
+
+// printChanges prints the paths that changed; values are never printed.
+func printChanges(changes []credentials.Change) {
+	if len(changes) == 0 {
+		_, _ = fmt.Fprint(os.Stdout, noChangesMessage)
+	}
+	for _, c := range changes {
+		_, _ = fmt.Fprintf(os.Stdout, "  %s %s\n", c.Kind.Symbol(), c.Key())
+	}
+}
+
+// summarizeChanges counts the changes as e.g. "+1 ~2 -0".
+func summarizeChanges(changes []credentials.Change) string {
+	counts := map[credentials.ChangeKind]int{}
+	for _, c := range changes {
+		counts[c.Kind]++
+	}
+	return fmt.Sprintf("+%d ~%d -%d", counts[credentials.Added], counts[credentials.Modified], counts[credentials.Removed])
+}
+
+func (cmd *BackupsList) Run(cli *Cli) error {
+	backups, err := listBackups(cli.Environment, cli.EncryptedCredentialsFile)
+	if err != nil {
+		return err
+	}
+	if len(backups) == 0 {
+		_, _ = fmt.Fprintf(os.Stdout, noBackupsTemplate, cli.EncryptedCredentialsFile)
+		return nil
+	}
+
+	// each backup is compared with the version that replaced it: the next newer backup, or the current file
+	newer, newerErr := cli.readTree(cli.EncryptedCredentialsFile)
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "ID\tCREATED\tCHANGES SINCE")
+	for _, b := range backups {
+		tree, err := cli.readTree(b.Path)
+		summary := "unknown, unable to decrypt with the current key"
+		if err == nil && newerErr == nil {
+			summary = summarizeChanges(credentials.Diff(tree, newer))
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\n", b.ID, b.Time.Local().Format(time.DateTime), summary)
+		newer, newerErr = tree, err
+	}
+	return w.Flush()
+}
+
+func (cmd *BackupsShow) Run(cli *Cli) error {
+	b, err := findBackup(cli.Environment, cli.EncryptedCredentialsFile, cmd.ID)
+	if err != nil {
+		return err
+	}
+
+	if cmd.Content {
+		e, err := os.ReadFile(b.Path)
+		if err != nil {
+			return fmt.Errorf("read backup failed: %w", err)
+		}
+		content, err := decryptCredentials(cli.MasterKey, e)
+		if err != nil {
+			return err
+		}
+		_, _ = fmt.Print(content)
+		return nil
+	}
+
+	old, err := cli.readTree(b.Path)
+	if err != nil {
+		return fmt.Errorf("read backup failed: %w", err)
+	}
+	current, err := cli.readTree(cli.EncryptedCredentialsFile)
+	if err != nil {
+		return fmt.Errorf("read %s failed: %w", cli.EncryptedCredentialsFile, err)
+	}
+	_, _ = fmt.Fprintf(os.Stdout, backupChangesTemplate, "backup "+b.ID, "the current credentials")
+	printChanges(credentials.Diff(old, current))
+	return nil
+}
+
+func (cmd *BackupsRestore) Run(cli *Cli) error {
+	b, err := findBackup(cli.Environment, cli.EncryptedCredentialsFile, cmd.ID)
+	if err != nil {
+		return err
+	}
+
+	lock, err := lockCredentials(cli.EncryptedCredentialsFile)
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+
+	// a backup encrypted with another key would leave the credentials unreadable
+	restored, err := cli.readTree(b.Path)
+	if err != nil {
+		return fmt.Errorf("backup %s can not be decrypted with the current key: %w", b.ID, err)
+	}
+	current, err := cli.readTree(cli.EncryptedCredentialsFile)
+	if err != nil {
+		current = map[string]any{}
+	}
+	_, _ = fmt.Fprintf(os.Stdout, backupChangesTemplate, "the current credentials", "backup "+b.ID)
+	printChanges(credentials.Diff(current, restored))
+	if !cmd.Yes && !confirm(restorePrompt) {
+		return fmt.Errorf("restore aborted")
+	}
+
+	content, err := os.ReadFile(b.Path)
+	if err != nil {
+		return fmt.Errorf("read backup failed: %w", err)
+	}
+	err = cli.backupFile(cli.Environment, cli.EncryptedCredentialsFile)
+	if err != nil {
+		return err
+	}
+	err = credentials.WriteFile(cli.EncryptedCredentialsFile, content, 0o666)
+	if err != nil {
+		return fmt.Errorf("unable to save encrypted file: %w", err)
+	}
+	_, _ = fmt.Fprintf(os.Stderr, restoredTemplate, cli.EncryptedCredentialsFile, b.ID)
+	return nil
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBackupDirFor(t *testing.T) {
+	t.Chdir(t.TempDir())
+	wd, err := os.Getwd()
+	assert.NoError(t, err)
+
+	assert.Equal(t, filepath.Join(backupDir, "_global"), backupDirFor("", filepath.Join("config", "credentials.yml.enc")))
+	assert.Equal(t, filepath.Join(backupDir, "production"), backupDirFor("production", filepath.Join("config", "credentials", "production.yml.enc")))
+
+	// any other file has its own backups, whether its path is relative or absolute
+	other := filepath.Join(backupDir, "_file", "config%2Fother.yml.enc")
+	assert.Equal(t, other, backupDirFor("", filepath.Join("config", "other.yml.enc")))
+	assert.Equal(t, other, backupDirFor("production", filepath.Join(wd, "config", "other.yml.enc")))
+}
+
+func TestBackupFile(t *testing.T) {
+	t.Chdir(t.TempDir())
+	cli := &Cli{KeepBackups: 1}
+	defaultFile := filepath.Join("config", "credentials.yml.enc")
+	otherFile := filepath.Join("config", "other.yml.enc")
+	assert.NoError(t, os.MkdirAll("config", 0o755))
+	assert.NoError(t, os.WriteFile(defaultFile, []byte("default"), 0o600))
+	assert.NoError(t, os.WriteFile(otherFile, []byte("other"), 0o600))
+
+	assert.NoError(t, cli.backupFile("", defaultFile))
+	assert.NoError(t, cli.backupFile("", otherFile))
+	assert.NoError(t, cli.backupFile("", otherFile))
+
+	// a backup of one file is never found for another
+	b, err := findBackup("", defaultFile, latestBackupID)
+	assert.NoError(t, err)
+	content, err := os.ReadFile(b.Path)
+	assert.NoError(t, err)
+	assert.Equal(t, "default", string(content))
+
+	backups, err := listBackups("", otherFile)
+	assert.NoError(t, err)
+	assert.Len(t, backups, 1)
+	content, err = os.ReadFile(backups[0].Path)
+	assert.NoError(t, err)
+	assert.Equal(t, "other", string(content))
+}
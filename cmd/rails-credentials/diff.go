@@ -0,0 +1,131 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/jamesits/go-rails-credentials/pkg/credentials"
+	"io"
+	"os"
+	"strings"
+)
+
+const workingTreeLabel = "working tree"
+
+type Diff struct {
+	From       string `arg:"" name:"rev1" help:"The git revision to compare from."`
+	To         string `arg:"" name:"rev2" optional:"" help:"The git revision to compare to (default: the working tree)."`
+	Redact     bool   `name:"redact" xor:"values" help:"Replace the values with hints, so the report can be posted publicly. This is the default."`
+	ShowValues bool   `name:"show-values" xor:"values" help:"Print the values instead of hints. The report can then not be posted publicly."`
+	RedactMode string `name:"redact-mode" enum:"hash,length,ends" default:"hash" help:"How values are redacted unless --show-values is given, one of: ${enum}."`
+	Format     string `name:"format" enum:"markdown,json" default:"markdown" help:"Report format, one of: ${enum}."`
+}
+
+type diffReport struct {
+	File    string       `json:"file"`
+	From    string       `json:"from"`
+	To      string       `json:"to"`
+	Changes []diffChange `json:"changes"`
+}
+
+type diffChange struct {
+	Path string                 `json:"path"`
+	Kind credentials.ChangeKind `json:"kind"`
+	Old  *string                `json:"old,omitempty"`
+	New  *string                `json:"new,omitempty"`
+}
+
+func (cmd *Diff) Run(cli *Cli) error {
+	if !insideGitWorkTree() {
+		return fmt.Errorf("diff needs a git repository")
+	}
+
+	from, err := cli.treeAt(cmd.From)
+	if err != nil {
+		return err
+	}
+	to, err := cli.treeAt(cmd.To)
+	if err != nil {
+		return err
+	}
+
+	report := diffReport{File: cli.EncryptedCredentialsFile, From: cmd.From, To: cmd.To, Changes: []diffChange{}}
+	if report.To == "" {
+		report.To = workingTreeLabel
+	}
+	r := newRedactor(cmd.RedactMode, cli.MasterKey)
+	format := func(v any) *string {
+		s := r.value(v)
+		if cmd.ShowValues {
+			s = credentials.FormatScalar(v)
+		}
+		return &s
+	}
+	for _, c := range credentials.Diff(from, to) {
+		d := diffChange{Path: c.Key(), Kind: c.Kind}
+		if c.Kind != credentials.Added {
+			d.Old = format(c.Old)
+		}
+		if c.Kind != credentials.Removed {
+			d.New = format(c.New)
+		}
+		report.Changes = append(report.Changes, d)
+	}
+
+	if cmd.Format == "json" {
+		e := json.NewEncoder(os.Stdout)
+		e.SetIndent("", "  ")
+		return e.Encode(report)
+	}
+	return writeDiffMarkdown(os.Stdout, report)
+}
+
+// treeAt decrypts the credentials file at a git revision, or in the working tree if rev is empty.
+func (cli *Cli) treeAt(rev string) (map[string]any, error) {
+	if rev == "" {
+		return cli.readTree(cli.EncryptedCredentialsFile)
+	}
+	tree, err := cli.treeAtWithKeys(rev, []string{cli.MasterKey})
+	if err != nil {
+		return nil, fmt.Errorf("%s at %s: %w", cli.EncryptedCredentialsFile, rev, err)
+	}
+	return tree, nil
+}
+
+func writeDiffMarkdown(w io.Writer, report diffReport) error {
+	_, err := fmt.Fprintf(w, "### Credentials changes in `%s` (%s → %s)\n\n", report.File, report.From, report.To)
+	if err != nil {
+		return err
+	}
+	if len(report.Changes) == 0 {
+		_, err = fmt.Fprintln(w, "No changes.")
+		return err
+	}
+
+	_, err = fmt.Fprint(w, "| Key | Change | Before | After |\n| --- | --- | --- | --- |\n")
+	if err != nil {
+		return err
+	}
+	cell := func(s *string) string {
+		if s == nil {
+			return ""
+		}
+		return markdownCode(*s)
+	}
+	for _, c := range report.Changes {
+		_, err = fmt.Fprintf(w, "| %s | %s | %s | %s |\n", markdownCode(c.Path), c.Kind, cell(c.Old), cell(c.New))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// markdownCode formats a string as inline code that is safe inside a table cell.
+func markdownCode(s string) string {
+	s = strings.ReplaceAll(s, "|", "\\|")
+	s = strings.ReplaceAll(s, "\n", " ")
+	if strings.Contains(s, "`") {
+		return "`` " + s + " ``"
+	}
+	return "`" + s + "`"
+}
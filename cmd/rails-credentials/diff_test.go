@@ -0,0 +1,33 @@
+package main
+
+import (
+	"github.com/alecthomas/kong"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestDiffFlags(t *testing.T) {
+	parse := func(args ...string) (*Cli, error) {
+		cli := &Cli{}
+		parser, err := kong.New(cli, kong.Exit(func(int) {}))
+		assert.NoError(t, err)
+		_, err = parser.Parse(args)
+		return cli, err
+	}
+
+	// values are redacted unless asked for, with or without --redact
+	cli, err := parse("diff", "--redact", "a", "b")
+	assert.NoError(t, err)
+	assert.Equal(t, Diff{From: "a", To: "b", Redact: true, RedactMode: "hash", Format: "markdown"}, cli.Diff)
+
+	cli, err = parse("diff", "a")
+	assert.NoError(t, err)
+	assert.False(t, cli.Diff.ShowValues)
+
+	cli, err = parse("diff", "--show-values", "a", "b")
+	assert.NoError(t, err)
+	assert.True(t, cli.Diff.ShowValues)
+
+	_, err = parse("diff", "--redact", "--show-values", "a", "b")
+	assert.Error(t, err)
+}
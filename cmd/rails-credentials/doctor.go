@@ -0,0 +1,295 @@
+package main
+
+import (
+	"fmt"
+	"github.com/jamesits/go-rails-credentials/pkg/credentials"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+type Doctor struct {
+	Fix bool `name:"fix" help:"Fix the problems that can be fixed automatically."`
+}
+
+// doctorFinding is a problem found by `doctor`. fix is nil if the problem can not be fixed automatically; hint then
+// tells the user what to do instead.
+type doctorFinding struct {
+	File    string
+	Problem string
+	Hint    string
+	fix     func() error
+}
+
+func (cmd *Doctor) Run(cli *Cli) error {
+	targets, err := discoverTargets()
+	if err != nil {
+		return err
+	}
+	keyFiles, err := discoverKeyFiles(targets)
+	if err != nil {
+		return err
+	}
+
+	var findings []doctorFinding
+	for _, f := range keyFiles {
+		findings = append(findings, checkKeyFile(f)...)
+	}
+	findings = append(findings, checkGitIgnore(keyFiles)...)
+	for _, t := range targets {
+		findings = append(findings, cli.checkEncryptedFile(t)...)
+		findings = append(findings, cli.checkTargetKey(t)...)
+	}
+
+	fixed := 0
+	for _, f := range findings {
+		_, _ = fmt.Fprintf(os.Stdout, "%s: %s\n", f.File, f.Problem)
+		switch {
+		case f.fix == nil:
+			_, _ = fmt.Fprintf(os.Stdout, "  %s\n", f.Hint)
+		case !cmd.Fix:
+			_, _ = fmt.Fprintf(os.Stdout, "  fixable with --fix: %s\n", f.Hint)
+		default:
+			err = f.fix()
+			if err != nil {
+				_, _ = fmt.Fprintf(os.Stdout, "  fix failed: %s\n", err)
+				continue
+			}
+			_, _ = fmt.Fprintf(os.Stdout, "  fixed: %s\n", f.Hint)
+			fixed++
+		}
+	}
+
+	if len(findings) == 0 {
+		_, _ = fmt.Fprintln(os.Stdout, "No problems found.")
+		return nil
+	}
+	if len(findings) == 1 {
+		_, _ = fmt.Fprintf(os.Stdout, "1 problem found, %d fixed.\n", fixed)
+	} else {
+		_, _ = fmt.Fprintf(os.Stdout, "%d problems found, %d fixed.\n", len(findings), fixed)
+	}
+	if fixed < len(findings) {
+		return exitStatus(1)
+	}
+	return nil
+}
+
+// discoverKeyFiles lists the key files of the targets and any other key file in the default locations.
+func discoverKeyFiles(targets []credentialsTarget) ([]string, error) {
+	seen := map[string]bool{}
+	masterKeyFile, _ := credentials.DefaultPaths("")
+	candidates := []string{masterKeyFile}
+	for _, t := range targets {
+		candidates = append(candidates, t.MasterKeyFile)
+	}
+	matches, err := filepath.Glob(filepath.Join(credentials.PerEnvironmentDir, "*.key"))
+	if err != nil {
+		return nil, fmt.Errorf("unable to list key files: %w", err)
+	}
+	candidates = append(candidates, matches...)
+
+	var ret []string
+	for _, c := range candidates {
+		if seen[c] {
+			continue
+		}
+		seen[c] = true
+		if _, err := os.Stat(c); err == nil {
+			ret = append(ret, c)
+		}
+	}
+	sort.Strings(ret)
+	return ret, nil
+}
+
+// checkKeyFile checks the content and the permissions of a key file.
+func checkKeyFile(path string) []doctorFinding {
+	var ret []doctorFinding
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return []doctorFinding{{File: path, Problem: fmt.Sprintf("unable to read: %s", err), Hint: "check the permissions of the file"}}
+	}
+	key := strings.TrimSpace(string(content))
+
+	// Rails reads the key file as is, so whitespace around the key breaks it even though we accept it
+	if key != string(content) {
+		ret = append(ret, doctorFinding{
+			File:    path,
+			Problem: "key has leading or trailing whitespace (e.g. a newline), which Rails does not accept",
+			Hint:    "remove the whitespace",
+			fix: func() error {
+				return credentials.WriteFile(path, []byte(key), 0o600)
+			},
+		})
+	}
+
+	err = credentials.ValidateMasterKey(key)
+	if err != nil {
+		ret = append(ret, doctorFinding{
+			File:    path,
+			Problem: err.Error(),
+			Hint:    "restore the key from a backup or ask your team for it",
+		})
+	}
+
+	// Windows does not have Unix permission bits
+	if runtime.GOOS != "windows" {
+		info, err := os.Stat(path)
+		if err == nil && info.Mode().Perm()&0o077 != 0 {
+			ret = append(ret, doctorFinding{
+				File:    path,
+				Problem: fmt.Sprintf("readable by users other than the owner (mode %04o)", info.Mode().Perm()),
+				Hint:    "chmod 600",
+				fix: func() error {
+					return os.Chmod(path, 0o600)
+				},
+			})
+		}
+	}
+
+	return ret
+}
+
+// checkGitIgnore checks that no key file can be committed.
+func checkGitIgnore(keyFiles []string) []doctorFinding {
+	if len(keyFiles) == 0 || !insideGitWorkTree() {
+		return nil
+	}
+
+	var ret []doctorFinding
+	for _, f := range keyFiles {
+		ignored, err := gitIgnored(f)
+		if err != nil {
+			ret = append(ret, doctorFinding{File: f, Problem: err.Error(), Hint: "check the git repository"})
+			continue
+		}
+		if !ignored {
+			pattern := "/" + filepath.ToSlash(f)
+			ret = append(ret, doctorFinding{
+				File:    f,
+				Problem: "not listed in .gitignore",
+				Hint:    fmt.Sprintf("add %s to .gitignore", pattern),
+				fix: func() error {
+					return appendGitIgnore(pattern)
+				},
+			})
+		}
+
+		tracked, err := gitTracked(f)
+		if err != nil {
+			ret = append(ret, doctorFinding{File: f, Problem: err.Error(), Hint: "check the git repository"})
+			continue
+		}
+		if tracked {
+			ret = append(ret, doctorFinding{
+				File:    f,
+				Problem: "tracked by git; the key is in the repository history and should be rotated",
+				Hint:    "remove the file from the index with git rm --cached",
+				fix: func() error {
+					// the other fixes may have changed the file, which only --force allows to unstage
+					_, err := git("rm", "--cached", "--force", "--quiet", "--", f)
+					return err
+				},
+			})
+		}
+	}
+	return ret
+}
+
+// checkEncryptedFile checks that an encrypted file is a single line without a line terminator, as Rails writes it.
+func (cli *Cli) checkEncryptedFile(t credentialsTarget) []doctorFinding {
+	content, err := os.ReadFile(t.EncryptedCredentialsFile)
+	if err != nil {
+		return []doctorFinding{{File: t.EncryptedCredentialsFile, Problem: fmt.Sprintf("unable to read: %s", err), Hint: "check the permissions of the file"}}
+	}
+
+	var problems []string
+	if strings.Contains(string(content), "\r") {
+		problems = append(problems, "has CRLF line endings")
+	}
+	if strings.HasSuffix(string(content), "\n") {
+		problems = append(problems, "ends with a newline")
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+
+	envelope := strings.Join(strings.Fields(string(content)), "")
+	return []doctorFinding{{
+		File:    t.EncryptedCredentialsFile,
+		Problem: strings.Join(problems, " and ") + ", probably changed by an editor or git; Rails never writes them",
+		Hint:    "remove the line terminators, and mark the file as binary in .gitattributes (*.yml.enc binary)",
+		fix: func() error {
+			err := cli.backupFile(t.Environment, t.EncryptedCredentialsFile)
+			if err != nil {
+				return err
+			}
+			return credentials.WriteFile(t.EncryptedCredentialsFile, []byte(envelope), 0o666)
+		},
+	}}
+}
+
+// checkTargetKey checks that there is a key to decrypt an encrypted file with, and that it does.
+func (cli *Cli) checkTargetKey(t credentialsTarget) []doctorFinding {
+	masterKey, source, err := cli.masterKey(t)
+	if err != nil {
+		return []doctorFinding{{File: t.EncryptedCredentialsFile, Problem: err.Error(), Hint: "check the permissions of the key file"}}
+	}
+
+	variable := "RAILS_MASTER_KEY"
+	if t.Environment != "" {
+		variable = environmentKeyVariable(t.Environment)
+	}
+	if masterKey == "" {
+		return []doctorFinding{{
+			File:    t.EncryptedCredentialsFile,
+			Problem: "no key to decrypt it with",
+			Hint:    fmt.Sprintf("ask your team for the key and put it in %s, or set %s", t.MasterKeyFile, variable),
+		}}
+	}
+
+	// checkEncryptedFile reports files that can not be read, and line terminators, which Decrypt does not accept
+	content, err := os.ReadFile(t.EncryptedCredentialsFile)
+	if err != nil {
+		return nil
+	}
+	_, err = credentials.Decrypt(masterKey, strings.Join(strings.Fields(string(content)), ""))
+	if err != nil {
+		return []doctorFinding{{
+			File:    t.EncryptedCredentialsFile,
+			Problem: fmt.Sprintf("the key from %s does not decrypt it: %s", source, err),
+			Hint:    fmt.Sprintf("ask your team for the matching key and put it in %s, or set %s", t.MasterKeyFile, variable),
+		}}
+	}
+	return nil
+}
+
+func appendGitIgnore(pattern string) error {
+	f, err := os.OpenFile(".gitignore", os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	line := pattern + "\n"
+	if info.Size() > 0 {
+		last := make([]byte, 1)
+		_, err = f.ReadAt(last, info.Size()-1)
+		if err != nil {
+			return err
+		}
+		if last[0] != '\n' {
+			line = "\n" + line
+		}
+	}
+	_, err = f.WriteAt([]byte(line), info.Size())
+	return err
+}
@@ -1,17 +1,20 @@
 package main
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"github.com/jamesits/go-rails-credentials/pkg/credentials"
 	"os"
 	"os/exec"
-	"path/filepath"
 	"strings"
+	"sync/atomic"
 )
 
 type Edit struct {
 	EditorCommand string `name:"editor" env:"VISUAL,EDITOR" default:"vi" help:"Your editor program."`
+	Force         bool   `name:"force" help:"Save the credentials even if they are not valid YAML or do not match the schema."`
 }
 
 const (
@@ -26,58 +29,147 @@ If you lose the key, no one, including you, can access anything encrypted with i
 	editorStartTemplate   = "Editing %s...\n"
 	decryptFailedTemplate = "Couldn't decrypt %s. Perhaps you passed the wrong key?\n"
 	savedTemplate         = "File encrypted and saved.\n"
+
+	invalidContentTemplate   = "The edited credentials are not valid: %s\n"
+	reopenEditorPrompt       = "Reopen the editor to fix it? Your changes are kept. [Y/n] "
+	forceSaveMessage         = "Saving anyway because of --force.\n"
+	schemaNotCheckedTemplate = "Warning: the edited credentials are not checked against the schema: %s\n"
+
+	concurrentChangeTemplate = "%s was changed by someone else while you were editing it.\n"
+	mergePrompt              = "Merge their changes into yours? Otherwise your changes are discarded. [Y/n] "
+	mergeConflictsTemplate   = "Merge conflicts: %d; resolve them in the editor.\n"
+	mergedMessage            = "Merged without conflicts; review the result in the editor.\n"
 )
 
 func (cmd *Edit) Run(cli *Cli) error {
 	var err error
 
 	// if creation of a new master key is needed
-	if cli.masterKeyGenerated {
-		_, _ = fmt.Fprintf(os.Stderr, masterKeyCreateTemplate, cli.MasterKey, cli.MasterKeyFile)
-		err = atomicWrite(cli.MasterKeyFile, []byte(cli.MasterKey), 0o600, 0o777)
-		if err != nil {
-			return fmt.Errorf("write master key file failed: %w", err)
-		}
+	err = cli.writeMasterKeyFile()
+	if err != nil {
+		return err
 	}
 
-	var rawCredentialsFileContent string
+	lock, err := lockCredentials(cli.EncryptedCredentialsFile)
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+
 	// read and decrypt the file
-	e, err := os.ReadFile(cli.EncryptedCredentialsFile)
-	if err == nil {
-		obj, err := credentials.Decrypt(cli.MasterKey, string(e))
+	rawCredentialsFileContent, digest, err := cli.readForEdit()
+	if err != nil {
+		return err
+	}
+
+	// write temp file
+	warnLeftoverPlaintext()
+	editorTempFile, err := createPlaintextFile(rawCredentialsFileContent)
+	if err != nil {
+		return err
+	}
+	defer editorTempFile.Remove()
+	editing := atomic.Bool{}
+	stopSignalHandling := editorTempFile.removeOnSignal(&editing)
+	defer stopSignalHandling()
+
+	// start the editor, and reopen it on the same file until the content is valid and does not conflict with changes
+	// made elsewhere, or the user gives up
+	var newRawCredentialsFileContent []byte
+	for {
+		_, _ = fmt.Fprintf(os.Stderr, editorStartTemplate, cli.EncryptedCredentialsFile)
+		editing.Store(true)
+		err = cmd.runEditor(editorTempFile.Path)
+		editing.Store(false)
+		if err != nil {
+			return err
+		}
+
+		// read back
+		newRawCredentialsFileContent, err = os.ReadFile(editorTempFile.Path)
 		if err != nil {
-			_, _ = fmt.Fprintf(os.Stderr, decryptFailedTemplate, cli.EncryptedCredentialsFile)
-			return fmt.Errorf("decrypt failed: %w", err)
+			return fmt.Errorf("unable to read temporary file: %w", err)
+		}
+		if string(newRawCredentialsFileContent) == rawCredentialsFileContent {
+			return nil
 		}
-		rawCredentialsFileContent, err = credentials.UnmarshalSingleString(obj)
+
+		err = cli.validateContent(string(newRawCredentialsFileContent))
 		if err != nil {
-			return fmt.Errorf("unmarshal failed: %w", err)
+			if !cmd.Force {
+				if !confirm(reopenEditorPrompt) {
+					return fmt.Errorf("credentials not saved: %w", err)
+				}
+				continue
+			}
+			_, _ = fmt.Fprint(os.Stderr, forceSaveMessage)
 		}
-	} else if errors.Is(err, os.ErrNotExist) {
-		rawCredentialsFileContent, err = credentials.NewCredentialsFileContent()
+
+		// the lock only keeps other edit sessions out; a git pull or another tool may still have changed the file
+		theirs, currentDigest, err := cli.readForEdit()
 		if err != nil {
-			return fmt.Errorf("render credentials.yml template failed: %w", err)
+			return err
+		}
+		if currentDigest == digest {
+			break
+		}
+		// readForEdit renders the template for a missing file, which is nothing to merge with
+		if currentDigest == "" {
+			return fmt.Errorf("credentials not saved: %s was deleted while editing", cli.EncryptedCredentialsFile)
+		}
+		_, _ = fmt.Fprintf(os.Stderr, concurrentChangeTemplate, cli.EncryptedCredentialsFile)
+		if !confirm(mergePrompt) {
+			return fmt.Errorf("credentials not saved: %s was changed while editing", cli.EncryptedCredentialsFile)
+		}
+		merged, conflicts, err := editorTempFile.merge(rawCredentialsFileContent, theirs, cli.EncryptedCredentialsFile)
+		if err != nil {
+			return fmt.Errorf("credentials not saved: %w", err)
+		}
+		rawCredentialsFileContent, digest = theirs, currentDigest
+		err = os.WriteFile(editorTempFile.Path, []byte(merged), 0o600)
+		if err != nil {
+			return fmt.Errorf("unable to write temporary file: %w", err)
+		}
+		if conflicts > 0 {
+			_, _ = fmt.Fprintf(os.Stderr, mergeConflictsTemplate, conflicts)
+		} else {
+			_, _ = fmt.Fprint(os.Stderr, mergedMessage)
 		}
 	}
 
-	// write temp file
-	editorTempFile, err := os.CreateTemp("", "*-credentials.yml")
-	if err != nil {
-		return fmt.Errorf("unable to create temporary file for editing: %w", err)
+	// encrypt the file
+	return cli.saveCredentials(string(newRawCredentialsFileContent))
+}
+
+// readForEdit decrypts the credentials file, or renders the template if it does not exist yet. The digest identifies
+// the encrypted content that was read, so a later call can tell whether the file has changed; it is empty if the file
+// does not exist.
+func (cli *Cli) readForEdit() (content string, digest string, err error) {
+	e, err := os.ReadFile(cli.EncryptedCredentialsFile)
+	if errors.Is(err, os.ErrNotExist) {
+		content, err = credentials.NewCredentialsFileContent()
+		if err != nil {
+			return "", "", fmt.Errorf("render credentials.yml template failed: %w", err)
+		}
+		return content, "", nil
+	} else if err != nil {
+		return "", "", fmt.Errorf("read encrypted file failed: %w", err)
 	}
-	err = editorTempFile.Close()
+
+	obj, err := credentials.Decrypt(cli.MasterKey, string(e))
 	if err != nil {
-		return fmt.Errorf("unable to close temporary file for editing: %w", err)
+		_, _ = fmt.Fprintf(os.Stderr, decryptFailedTemplate, cli.EncryptedCredentialsFile)
+		return "", "", fmt.Errorf("decrypt failed: %w", err)
 	}
-	defer os.Remove(editorTempFile.Name())
-
-	err = os.WriteFile(editorTempFile.Name(), []byte(rawCredentialsFileContent), 0o600)
+	content, err = credentials.UnmarshalSingleString(obj)
 	if err != nil {
-		return fmt.Errorf("unable to write temporary file for editing: %w", err)
+		return "", "", fmt.Errorf("unmarshal failed: %w", err)
 	}
+	sum := sha256.Sum256(e)
+	return content, hex.EncodeToString(sum[:]), nil
+}
 
-	// start the editor
-	_, _ = fmt.Fprintf(os.Stderr, editorStartTemplate, cli.EncryptedCredentialsFile)
+func (cmd *Edit) runEditor(file string) error {
 	editorCommandArgs := strings.Fields(cmd.EditorCommand)
 	editorCommandPath, err := exec.LookPath(editorCommandArgs[0])
 	if err != nil {
@@ -85,7 +177,7 @@ func (cmd *Edit) Run(cli *Cli) error {
 	}
 	editorCmd := exec.Cmd{
 		Path:   editorCommandPath,
-		Args:   append(editorCommandArgs, editorTempFile.Name()),
+		Args:   append(editorCommandArgs, file),
 		Stdin:  os.Stdin,
 		Stdout: os.Stdout,
 		Stderr: os.Stderr,
@@ -94,55 +186,84 @@ func (cmd *Edit) Run(cli *Cli) error {
 	if err != nil || !editorCmd.ProcessState.Success() {
 		return fmt.Errorf("editor failed with code %d: %w", editorCmd.ProcessState.ExitCode(), err)
 	}
+	return nil
+}
 
-	// read back
-	newRawCredentialsFileContent, err := os.ReadFile(editorTempFile.Name())
+// validateContent checks the edited plaintext before it is encrypted, and prints every problem found. A schema that can
+// not be loaded is only a warning.
+func (cli *Cli) validateContent(content string) error {
+	tree, err := credentials.ParseContent(content)
 	if err != nil {
-		return fmt.Errorf("unable to read temporary file: %w", err)
-	}
-	if string(newRawCredentialsFileContent) == rawCredentialsFileContent {
-		return nil
+		var yamlErr *credentials.YAMLError
+		if errors.As(err, &yamlErr) {
+			printYAMLError(content, yamlErr)
+		} else {
+			_, _ = fmt.Fprintf(os.Stderr, invalidContentTemplate, err)
+		}
+		return err
 	}
 
-	// encrypt the file
-	newObject, err := credentials.MarshalSingleString(string(newRawCredentialsFileContent))
+	// no edit to the credentials can fix the schema file, so it does not keep them from being saved
+	schema, err := cli.loadSchema()
 	if err != nil {
-		return fmt.Errorf("unable to marshal object: %w", err)
+		_, _ = fmt.Fprintf(os.Stderr, schemaNotCheckedTemplate, err)
+		return nil
 	}
-	newEncryptedCredentialsFileContent, err := credentials.Encrypt(cli.MasterKey, newObject)
-	if err != nil {
-		return fmt.Errorf("unable to encrypt: %w", err)
+	if schema == nil {
+		return nil
 	}
-
-	err = atomicWrite(cli.EncryptedCredentialsFile, []byte(newEncryptedCredentialsFileContent), 0o666, 0o777)
-	if err != nil {
-		return fmt.Errorf("unable to save encrypted file: %w", err)
+	violations := schema.ForEnvironment(cli.Environment).Validate(tree)
+	if len(violations) > 0 {
+		printSchemaViolations("the edited credentials", cli.SchemaFile, violations)
+		return fmt.Errorf("%d schema violations", len(violations))
 	}
-	_, _ = fmt.Fprint(os.Stderr, savedTemplate)
 	return nil
 }
 
-func atomicWrite(path string, content []byte, filePerm os.FileMode, dirPerm os.FileMode) error {
-	var err error
+// printYAMLError prints the error with the offending line and the one before it.
+func printYAMLError(content string, yamlErr *credentials.YAMLError) {
+	_, _ = fmt.Fprintf(os.Stderr, invalidContentTemplate, yamlErr)
+	if yamlErr.Line == 0 {
+		return
+	}
 
-	temp := path + ".tmp"
-	_ = os.Remove(temp)
+	lines := strings.Split(content, "\n")
+	for i := max(yamlErr.Line-2, 0); i < yamlErr.Line && i < len(lines); i++ {
+		_, _ = fmt.Fprintf(os.Stderr, "%5d | %s\n", i+1, lines[i])
+	}
+}
 
-	err = os.MkdirAll(filepath.Dir(path), dirPerm)
-	if err != nil {
-		return fmt.Errorf("unable to create directory: %w", err)
+// writeMasterKeyFile creates the master key file if the key was generated in this run.
+func (cli *Cli) writeMasterKeyFile() error {
+	if !cli.masterKeyGenerated || cli.masterKeyFileWritten {
+		return nil
 	}
 
-	err = os.WriteFile(temp, content, filePerm)
+	_, _ = fmt.Fprintf(os.Stderr, masterKeyCreateTemplate, cli.MasterKey, cli.MasterKeyFile)
+	err := credentials.WriteFile(cli.MasterKeyFile, []byte(cli.MasterKey), 0o600)
 	if err != nil {
-		return fmt.Errorf("unable to write temporary file: %w", err)
+		return fmt.Errorf("write master key file failed: %w", err)
 	}
-	defer os.Remove(temp)
+	cli.masterKeyFileWritten = true
+	return nil
+}
 
-	err = os.Rename(temp, path)
+// saveCredentials encrypts the plaintext and replaces the credentials file with it.
+// Every command that modifies the credentials goes through here.
+func (cli *Cli) saveCredentials(content string) error {
+	err := cli.writeMasterKeyFile()
 	if err != nil {
-		return fmt.Errorf("unable to overwrite destination file: %w", err)
+		return err
 	}
 
+	err = cli.backupFile(cli.Environment, cli.EncryptedCredentialsFile)
+	if err != nil {
+		return err
+	}
+	err = credentials.WriteEncryptedFile(cli.EncryptedCredentialsFile, cli.MasterKey, content)
+	if err != nil {
+		return fmt.Errorf("unable to save encrypted file: %w", err)
+	}
+	_, _ = fmt.Fprint(os.Stderr, savedTemplate)
 	return nil
 }
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestValidateContent(t *testing.T) {
+	cli := &Cli{SchemaFile: filepath.Join(t.TempDir(), "credentials.schema.json")}
+
+	// without a schema, only the YAML is checked
+	assert.NoError(t, cli.validateContent("a: 1\n"))
+	assert.Error(t, cli.validateContent("a: 1\n  b: [\n"))
+
+	assert.NoError(t, os.WriteFile(cli.SchemaFile, []byte(`{"type": "object", "required": ["b"]}`), 0o600))
+	assert.Error(t, cli.validateContent("a: 1\n"))
+
+	// editing the credentials can not fix a broken schema
+	assert.NoError(t, os.WriteFile(cli.SchemaFile, []byte(`{bad`), 0o600))
+	assert.NoError(t, cli.validateContent("a: 1\n"))
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+type Exec struct {
+	envOptions `embed:""`
+
+	Command []string `arg:"" passthrough:"" help:"Command to run and its arguments, usually after \"--\"."`
+}
+
+func (cmd *Exec) Run(cli *Cli) error {
+	rawString, err := cli.readCredentials()
+	if err != nil {
+		return err
+	}
+
+	variables, err := cmd.variables(rawString)
+	if err != nil {
+		return fmt.Errorf("flatten credentials failed: %w", err)
+	}
+
+	// kong keeps the "--" separator in passthrough arguments
+	command := cmd.Command
+	if len(command) > 0 && command[0] == "--" {
+		command = command[1:]
+	}
+	if len(command) == 0 {
+		return fmt.Errorf("no command specified")
+	}
+
+	commandPath, err := exec.LookPath(command[0])
+	if err != nil {
+		return fmt.Errorf("unable to find executable %s: %w", command[0], err)
+	}
+
+	return execCommand(commandPath, command, mergeEnviron(os.Environ(), variables))
+}
+
+// mergeEnviron returns environ with the variables added, replacing existing entries of the same name.
+func mergeEnviron(environ []string, variables []envVariable) []string {
+	overridden := make(map[string]struct{}, len(variables))
+	for _, v := range variables {
+		overridden[v.Name] = struct{}{}
+	}
+
+	ret := make([]string, 0, len(environ)+len(variables))
+	for _, e := range environ {
+		name, _, _ := strings.Cut(e, "=")
+		if _, ok := overridden[name]; !ok {
+			ret = append(ret, e)
+		}
+	}
+	for _, v := range variables {
+		ret = append(ret, v.Name+"="+v.Value)
+	}
+	return ret
+}
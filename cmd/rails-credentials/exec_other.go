@@ -0,0 +1,62 @@
+//go:build !unix
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+)
+
+// execCommand runs the command as a child process on platforms without exec(2), relaying interrupts to it where the
+// platform supports that, and exits with its exit status.
+func execCommand(path string, args []string, environ []string) error {
+	c := exec.Cmd{
+		Path:   path,
+		Args:   args,
+		Env:    environ,
+		Stdin:  os.Stdin,
+		Stdout: os.Stdout,
+		Stderr: os.Stderr,
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt)
+	defer signal.Stop(signals)
+
+	err := c.Start()
+	if err != nil {
+		return fmt.Errorf("start %s failed: %w", path, err)
+	}
+
+	go func() {
+		for s := range signals {
+			_ = c.Process.Signal(s)
+		}
+	}()
+
+	err = c.Wait()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitStatus(childExitStatus(exitErr.ProcessState))
+	}
+	return err
+}
+
+// childExitStatus returns the exit status of the child, or 128 plus the signal number like a shell if it was killed
+// by a signal, which ExitCode reports as -1.
+func childExitStatus(state *os.ProcessState) int {
+	if code := state.ExitCode(); code >= 0 {
+		return code
+	}
+	if ws, ok := state.Sys().(interface {
+		Signaled() bool
+		Signal() syscall.Signal
+	}); ok && ws.Signaled() && ws.Signal() > 0 {
+		return 128 + int(ws.Signal())
+	}
+	return 1
+}
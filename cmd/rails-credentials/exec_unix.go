@@ -0,0 +1,15 @@
+//go:build unix
+
+package main
+
+import (
+	"fmt"
+	"syscall"
+)
+
+// execCommand replaces the current process with the command, so signals reach it directly and its exit status
+// becomes ours. The credentials only ever live in memory and in the environment of the new process.
+func execCommand(path string, args []string, environ []string) error {
+	err := syscall.Exec(path, args, environ)
+	return fmt.Errorf("exec %s failed: %w", path, err)
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/jamesits/go-rails-credentials/pkg/credentials"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// envOptions controls how the credentials tree is flattened into environment variables.
+// It is shared by every command that produces environment variables.
+type envOptions struct {
+	Separator string   `name:"separator" default:"__" help:"Separator between the segments of a key path in variable names."`
+	Prefix    string   `name:"prefix" help:"Prefix prepended to every variable name."`
+	Only      []string `name:"only" sep:"," placeholder:"PATH" help:"Only include keys under these dotted paths, e.g. \"aws,smtp.password\"."`
+	Exclude   []string `name:"exclude" sep:"," placeholder:"PATH" help:"Skip keys under these dotted paths."`
+}
+
+type envVariable struct {
+	Name  string
+	Value string
+}
+
+// variables flattens the decrypted credentials into environment variables sorted by name.
+func (o *envOptions) variables(content string) ([]envVariable, error) {
+	tree, err := credentials.ParseContent(content)
+	if err != nil {
+		return nil, err
+	}
+
+	only := parsePaths(o.Only)
+	exclude := parsePaths(o.Exclude)
+
+	var ret []envVariable
+	sources := map[string]string{}
+	for _, leaf := range credentials.Leaves(tree) {
+		if len(only) > 0 && !matchAnyPath(leaf.Path, only) {
+			continue
+		}
+		if matchAnyPath(leaf.Path, exclude) {
+			continue
+		}
+
+		name := o.variableName(leaf.Path)
+		if previous, ok := sources[name]; ok {
+			return nil, fmt.Errorf("keys %s and %s both map to variable %s", previous, leaf.Key(), name)
+		}
+		sources[name] = leaf.Key()
+		ret = append(ret, envVariable{Name: name, Value: credentials.FormatScalar(leaf.Value)})
+	}
+
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Name < ret[j].Name })
+	return ret, nil
+}
+
+// variableName converts a key path into a portable variable name: upper case, with everything other than letters,
+// digits and underscores replaced by underscores.
+func (o *envOptions) variableName(path []string) string {
+	segments := make([]string, len(path))
+	for i, s := range path {
+		segments[i] = strings.Map(func(r rune) rune {
+			switch {
+			case r >= 'a' && r <= 'z':
+				return r - 'a' + 'A'
+			case (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '_':
+				return r
+			default:
+				return '_'
+			}
+		}, s)
+	}
+
+	name := o.Prefix + strings.Join(segments, o.Separator)
+	if name == "" || (name[0] >= '0' && name[0] <= '9') {
+		name = "_" + name
+	}
+	return name
+}
+
+func parsePaths(in []string) [][]string {
+	ret := make([][]string, 0, len(in))
+	for _, p := range in {
+		if p = strings.TrimSpace(p); p != "" {
+			ret = append(ret, credentials.ParsePath(p))
+		}
+	}
+	return ret
+}
+
+func matchAnyPath(path []string, prefixes [][]string) bool {
+	for _, prefix := range prefixes {
+		if credentials.HasPathPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+type Export struct {
+	envOptions `embed:""`
+
+	Format string `name:"format" enum:"dotenv,shell,json,github-actions" default:"dotenv" help:"Output format, one of: ${enum}. For github-actions, variables are appended to the file named by $GITHUB_ENV when it is set."`
+}
+
+func (cmd *Export) Run(cli *Cli) error {
+	rawString, err := cli.readCredentials()
+	if err != nil {
+		return err
+	}
+
+	variables, err := cmd.variables(rawString)
+	if err != nil {
+		return fmt.Errorf("flatten credentials failed: %w", err)
+	}
+
+	switch cmd.Format {
+	case "dotenv":
+		return writeDotenv(os.Stdout, variables)
+	case "shell":
+		return writeShell(os.Stdout, variables)
+	case "json":
+		return writeJSON(os.Stdout, variables)
+	case "github-actions":
+		return writeGitHubActions(os.Stdout, variables)
+	default:
+		return fmt.Errorf("unknown format %s", cmd.Format)
+	}
+}
+
+// writeDotenv single-quotes values where possible since single quotes are literal in every dotenv dialect.
+// Values containing newlines or single quotes fall back to double quotes with backslash escapes.
+func writeDotenv(w io.Writer, variables []envVariable) error {
+	for _, v := range variables {
+		var err error
+		if !strings.ContainsAny(v.Value, "'\r\n") {
+			_, err = fmt.Fprintf(w, "%s='%s'\n", v.Name, v.Value)
+		} else {
+			r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, `$`, `\$`, "\n", `\n`, "\r", `\r`)
+			_, err = fmt.Fprintf(w, "%s=\"%s\"\n", v.Name, r.Replace(v.Value))
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeShell produces POSIX shell `export` statements; single quotes keep multi-line values literal.
+func writeShell(w io.Writer, variables []envVariable) error {
+	for _, v := range variables {
+		_, err := fmt.Fprintf(w, "export %s='%s'\n", v.Name, strings.ReplaceAll(v.Value, `'`, `'\''`))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeJSON(w io.Writer, variables []envVariable) error {
+	m := make(map[string]string, len(variables))
+	for _, v := range variables {
+		m[v.Name] = v.Value
+	}
+	e := json.NewEncoder(w)
+	e.SetIndent("", "  ")
+	return e.Encode(m)
+}
+
+// writeGitHubActions masks every value in the job log, then writes the variables in the $GITHUB_ENV syntax.
+// https://docs.github.com/en/actions/reference/workflows-and-actions/workflow-commands
+func writeGitHubActions(w io.Writer, variables []envVariable) error {
+	for _, v := range variables {
+		// masks only apply to single lines, so every line of a multi-line value is masked on its own
+		for _, line := range strings.Split(v.Value, "\n") {
+			line = strings.TrimRight(line, "\r")
+			if line == "" {
+				continue
+			}
+			_, err := fmt.Fprintf(w, "::add-mask::%s\n", line)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	out := w
+	if p := os.Getenv("GITHUB_ENV"); p != "" {
+		f, err := os.OpenFile(p, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+		if err != nil {
+			return fmt.Errorf("unable to open GITHUB_ENV file: %w", err)
+		}
+		defer f.Close()
+		out = f
+	}
+
+	for _, v := range variables {
+		var err error
+		if !strings.ContainsAny(v.Value, "\r\n") {
+			_, err = fmt.Fprintf(out, "%s=%s\n", v.Name, v.Value)
+		} else {
+			var delimiter string
+			delimiter, err = heredocDelimiter(v.Value)
+			if err != nil {
+				return err
+			}
+			_, err = fmt.Fprintf(out, "%s<<%s\n%s\n%s\n", v.Name, delimiter, v.Value, delimiter)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// heredocDelimiter returns a random delimiter that does not occur in the value.
+func heredocDelimiter(value string) (string, error) {
+	r := make([]byte, 8)
+	for {
+		_, err := rand.Read(r)
+		if err != nil {
+			return "", fmt.Errorf("unable to generate randomness: %w", err)
+		}
+		d := "ghadelimiter_" + hex.EncodeToString(r)
+		if !strings.Contains(value, d) {
+			return d, nil
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// git runs git in the current directory and returns its standard output without the trailing newline.
+func git(args ...string) (string, error) {
+	stdout := bytes.Buffer{}
+	stderr := bytes.Buffer{}
+	c := exec.Command("git", args...)
+	c.Stdout = &stdout
+	c.Stderr = &stderr
+	err := c.Run()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && stderr.Len() > 0 {
+			return stdout.String(), fmt.Errorf("git %s: %s", args[0], strings.TrimSpace(stderr.String()))
+		}
+		return stdout.String(), fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return strings.TrimRight(stdout.String(), "\n"), nil
+}
+
+// gitExitCode returns the exit status of a failed git command, or -1 if git did not run.
+func gitExitCode(args ...string) int {
+	err := exec.Command("git", args...).Run()
+	if err == nil {
+		return 0
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+	return -1
+}
+
+// insideGitWorkTree reports whether the current directory is inside a git work tree.
+func insideGitWorkTree() bool {
+	out, err := git("rev-parse", "--is-inside-work-tree")
+	return err == nil && out == "true"
+}
+
+// gitIgnored reports whether a path is ignored by git.
+func gitIgnored(path string) (bool, error) {
+	switch gitExitCode("check-ignore", "-q", "--no-index", "--", path) {
+	case 0:
+		return true, nil
+	case 1:
+		return false, nil
+	default:
+		return false, fmt.Errorf("git check-ignore failed for %s", path)
+	}
+}
+
+// gitTracked reports whether a path is in the git index.
+func gitTracked(path string) (bool, error) {
+	switch gitExitCode("ls-files", "--error-unmatch", "--", path) {
+	case 0:
+		return true, nil
+	case 1:
+		return false, nil
+	default:
+		return false, fmt.Errorf("git ls-files failed for %s", path)
+	}
+}
+
+// gitMergeFile runs a three-way merge of the files with git merge-file and returns the result, which contains
+// conflict markers if there were conflicts, and the number of conflicts.
+func gitMergeFile(ours string, base string, theirs string, labels [3]string) (string, int, error) {
+	stdout := bytes.Buffer{}
+	stderr := bytes.Buffer{}
+	c := exec.Command("git", "merge-file", "-p", "-L", labels[0], "-L", labels[1], "-L", labels[2], ours, base, theirs)
+	c.Stdout = &stdout
+	c.Stderr = &stderr
+	err := c.Run()
+	if err == nil {
+		return stdout.String(), 0, nil
+	}
+
+	// git merge-file exits with the number of conflicts, or with a negative status on errors
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() > 0 && exitErr.ExitCode() < 128 {
+		return stdout.String(), exitErr.ExitCode(), nil
+	}
+	if stderr.Len() > 0 {
+		return "", 0, fmt.Errorf("git merge-file: %s", strings.TrimSpace(stderr.String()))
+	}
+	return "", 0, fmt.Errorf("git merge-file: %w", err)
+}
+
+// gitCommit is a commit as listed by gitLog.
+type gitCommit struct {
+	Hash    string
+	Parents []string
+	Author  string
+	Date    time.Time
+	Subject string
+}
+
+// gitLog lists the commits that changed a file, newest first. The path is relative to the current directory.
+func gitLog(path string) ([]gitCommit, error) {
+	out, err := git("log", "--format=%H%x1f%P%x1f%an <%ae>%x1f%aI%x1f%s", "--", path)
+	if err != nil {
+		return nil, err
+	}
+
+	var ret []gitCommit
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Split(line, "\x1f")
+		if len(fields) != 5 {
+			continue
+		}
+		date, err := time.Parse(time.RFC3339, fields[3])
+		if err != nil {
+			return nil, fmt.Errorf("git log: %w", err)
+		}
+		ret = append(ret, gitCommit{Hash: fields[0], Parents: strings.Fields(fields[1]), Author: fields[2], Date: date, Subject: fields[4]})
+	}
+	return ret, nil
+}
+
+// gitShowFile returns the content of a file at a revision. The path is relative to the current directory.
+// A file that does not exist at that revision is reported with ok set to false.
+func gitShowFile(rev string, path string) (content []byte, ok bool, err error) {
+	_, err = git("rev-parse", "--verify", "--quiet", rev+"^{commit}")
+	if err != nil {
+		return nil, false, fmt.Errorf("unknown revision %s", rev)
+	}
+
+	if filepath.IsAbs(path) {
+		wd, err := os.Getwd()
+		if err != nil {
+			return nil, false, err
+		}
+		path, err = filepath.Rel(wd, path)
+		if err != nil {
+			return nil, false, err
+		}
+	}
+	spec := rev + ":./" + filepath.ToSlash(path)
+	if gitExitCode("cat-file", "-e", spec) != 0 {
+		return nil, false, nil
+	}
+	out, err := git("show", spec)
+	if err != nil {
+		return nil, false, err
+	}
+	return []byte(out), true, nil
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"fmt"
+	"github.com/jamesits/go-rails-credentials/pkg/credentials"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+const (
+	noHistoryTemplate = "No commits changed %s.\n"
+)
+
+type History struct {
+	Path    string   `arg:"" optional:"" help:"Only list changes of this key path and the keys below it, e.g. stripe.api_key."`
+	OldKeys []string `name:"old-key" sep:"," env:"RAILS_OLD_MASTER_KEYS" placeholder:"KEY" help:"Earlier master keys to try on revisions the current key can not decrypt. For security, please use the environment variable."`
+	Values  string   `name:"values" enum:"hidden,hash,length,plain" default:"hidden" help:"How to print the values, one of: ${enum}."`
+}
+
+type historyEntry struct {
+	Commit gitCommit
+	Change credentials.Change
+	Error  string
+}
+
+func (cmd *History) Run(cli *Cli) error {
+	if !insideGitWorkTree() {
+		return fmt.Errorf("history needs a git repository")
+	}
+	prefix := credentials.ParsePath(cmd.Path)
+
+	commits, err := gitLog(cli.EncryptedCredentialsFile)
+	if err != nil {
+		return err
+	}
+	if len(commits) == 0 {
+		_, _ = fmt.Fprintf(os.Stdout, noHistoryTemplate, cli.EncryptedCredentialsFile)
+		return nil
+	}
+
+	keys := []string{cli.MasterKey}
+	for _, k := range cmd.OldKeys {
+		keys = append(keys, credentials.SanitizeMasterKey(k))
+	}
+
+	// each commit is compared with its parents, not with the previous commit in the log, which can be on another
+	// branch; the entries of each commit are collected separately, so they can be printed newest commit first but in
+	// key order
+	trees := map[string]map[string]any{}
+	treeAt := func(rev string) (map[string]any, error) {
+		if tree, ok := trees[rev]; ok {
+			return tree, nil
+		}
+		tree, err := cli.treeAtWithKeys(rev, keys)
+		if err != nil {
+			return nil, err
+		}
+		trees[rev] = tree
+		return tree, nil
+	}
+	perCommit := make([][]historyEntry, len(commits))
+	for i, c := range commits {
+		changes, err := commitChanges(c, treeAt)
+		if err != nil {
+			perCommit[i] = []historyEntry{{Commit: c, Error: err.Error()}}
+			continue
+		}
+		for _, change := range changes {
+			if credentials.HasPathPrefix(change.Path, prefix) {
+				perCommit[i] = append(perCommit[i], historyEntry{Commit: c, Change: change})
+			}
+		}
+	}
+	var entries []historyEntry
+	for _, e := range perCommit {
+		entries = append(entries, e...)
+	}
+
+	r := newRedactor(cmd.Values, cli.MasterKey)
+	format := func(v any) string {
+		switch cmd.Values {
+		case "plain":
+			return credentials.FormatScalar(v)
+		default:
+			return r.value(v)
+		}
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	header := "COMMIT\tDATE\tAUTHOR\tCHANGE\tKEY"
+	if cmd.Values != "hidden" {
+		header += "\tBEFORE\tAFTER"
+	}
+	_, _ = fmt.Fprintln(w, header)
+	for _, e := range entries {
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t", e.Commit.Hash[:10], e.Commit.Date.Local().Format(time.DateTime), e.Commit.Author)
+		if e.Error != "" {
+			_, _ = fmt.Fprintf(w, "?\t%s\n", e.Error)
+			continue
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s", e.Change.Kind, e.Change.Key())
+		if cmd.Values != "hidden" {
+			var before, after string
+			if e.Change.Kind != credentials.Added {
+				before = format(e.Change.Old)
+			}
+			if e.Change.Kind != credentials.Removed {
+				after = format(e.Change.New)
+			}
+			_, _ = fmt.Fprintf(w, "\t%s\t%s", before, after)
+		}
+		_, _ = fmt.Fprintln(w)
+	}
+	return w.Flush()
+}
+
+// commitChanges returns what a commit changed compared with its parents. A merge commit only changes the keys that
+// differ from every parent, e.g. a resolved conflict; the other changes are listed at the commits that made them.
+// A root commit is compared with an empty tree.
+func commitChanges(c gitCommit, treeAt func(rev string) (map[string]any, error)) ([]credentials.Change, error) {
+	tree, err := treeAt(c.Hash)
+	if err != nil {
+		return nil, err
+	}
+	if len(c.Parents) == 0 {
+		return credentials.Diff(map[string]any{}, tree), nil
+	}
+
+	var changes []credentials.Change
+	counts := map[string]int{}
+	for i, p := range c.Parents {
+		parent, err := treeAt(p)
+		if err != nil {
+			return nil, fmt.Errorf("parent %s: %w", p[:10], err)
+		}
+		for _, change := range credentials.Diff(parent, tree) {
+			counts[change.Key()]++
+			if i == 0 {
+				changes = append(changes, change)
+			}
+		}
+	}
+
+	var ret []credentials.Change
+	for _, change := range changes {
+		if counts[change.Key()] == len(c.Parents) {
+			ret = append(ret, change)
+		}
+	}
+	return ret, nil
+}
+
+// treeAtWithKeys decrypts the credentials file at a git revision with the first key that works. A file that does not
+// exist at that revision is an empty tree, so added and deleted files show up as changes.
+func (cli *Cli) treeAtWithKeys(rev string, keys []string) (map[string]any, error) {
+	e, ok, err := gitShowFile(rev, cli.EncryptedCredentialsFile)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return map[string]any{}, nil
+	}
+
+	for _, k := range keys {
+		content, err := decryptCredentials(k, e)
+		if err != nil {
+			continue
+		}
+		tree, err := credentials.ParseContent(content)
+		if err != nil {
+			return nil, fmt.Errorf("invalid content: %w", err)
+		}
+		return tree, nil
+	}
+	return nil, fmt.Errorf("unable to decrypt with the given keys")
+}
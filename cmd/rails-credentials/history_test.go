@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"github.com/jamesits/go-rails-credentials/pkg/credentials"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestCommitChanges(t *testing.T) {
+	trees := map[string]map[string]any{
+		"root":   {"a": 1},
+		"main":   {"a": 1, "b": 1},
+		"branch": {"a": 2},
+		// merge of main and branch, with c added while resolving it
+		"merge": {"a": 2, "b": 1, "c": 1},
+	}
+	treeAt := func(rev string) (map[string]any, error) {
+		tree, ok := trees[rev]
+		if !ok {
+			return nil, fmt.Errorf("unable to decrypt")
+		}
+		return tree, nil
+	}
+	summary := func(changes []credentials.Change) []string {
+		var ret []string
+		for _, c := range changes {
+			ret = append(ret, string(c.Kind)+" "+c.Key())
+		}
+		return ret
+	}
+
+	changes, err := commitChanges(gitCommit{Hash: "root"}, treeAt)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"added a"}, summary(changes))
+
+	changes, err = commitChanges(gitCommit{Hash: "branch", Parents: []string{"root"}}, treeAt)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"modified a"}, summary(changes))
+
+	// a and b were changed on either branch, only c by the merge itself
+	changes, err = commitChanges(gitCommit{Hash: "merge", Parents: []string{"main", "branch"}}, treeAt)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"added c"}, summary(changes))
+
+	_, err = commitChanges(gitCommit{Hash: "merge", Parents: []string{"main", "0123456789abcdef"}}, treeAt)
+	assert.Error(t, err)
+}
@@ -0,0 +1,512 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/jamesits/go-rails-credentials/pkg/credentials"
+	"gopkg.in/yaml.v3"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+type Import struct {
+	File   string `arg:"" type:"existingfile" help:"File to import."`
+	Format string `name:"format" enum:"auto,dotenv,json,yaml,secrets" default:"auto" help:"Format of the file, one of: ${enum}. \"auto\" guesses from the file name."`
+	Under  string `name:"under" placeholder:"PATH" help:"Dotted path to import the values under."`
+
+	Merge     bool `name:"merge" xor:"mode" help:"Merge the imported values into the existing credentials. This is the default."`
+	Replace   bool `name:"replace" xor:"mode" help:"Replace everything under --under, or the whole file, with the imported values."`
+	Overwrite bool `name:"overwrite" help:"When merging, overwrite existing keys that have a different value instead of failing."`
+
+	Separator          string `name:"separator" default:"__" help:"Separator between the segments of a key path in dotenv variable names."`
+	Prefix             string `name:"prefix" help:"Only import dotenv variables starting with this prefix, and strip it from the key."`
+	KeepCase           bool   `name:"keep-case" help:"Keep the case of dotenv variable names instead of converting them to lower case."`
+	SecretsEnvironment string `name:"secrets-environment" help:"Environment section to import from a secrets.yml, merged with its shared section. Defaults to --environment."`
+}
+
+type importConflict struct {
+	Path   string
+	Reason string
+}
+
+func (cmd *Import) Run(cli *Cli) error {
+	imported, err := cmd.load(cli)
+	if err != nil {
+		return fmt.Errorf("unable to load %s: %w", cmd.File, err)
+	}
+
+	// read the current credentials, or start from the same template as `edit`
+	var rawString string
+	if _, err = os.Stat(cli.EncryptedCredentialsFile); err == nil {
+		rawString, err = cli.readCredentials()
+		if err != nil {
+			return err
+		}
+	} else if errors.Is(err, os.ErrNotExist) {
+		rawString, err = credentials.NewCredentialsFileContent()
+		if err != nil {
+			return fmt.Errorf("render credentials.yml template failed: %w", err)
+		}
+	} else {
+		return fmt.Errorf("unable to open %s: %w", cli.EncryptedCredentialsFile, err)
+	}
+
+	existing, err := credentials.ParseContent(rawString)
+	if err != nil {
+		return fmt.Errorf("existing credentials are not valid: %w", err)
+	}
+	doc, err := parseDocument(rawString)
+	if err != nil {
+		return fmt.Errorf("existing credentials are not valid: %w", err)
+	}
+
+	under := credentials.ParsePath(cmd.Under)
+	if cmd.Replace {
+		err = replaceNode(doc, under, imported)
+		if err != nil {
+			return err
+		}
+		_, _ = fmt.Fprintf(os.Stderr, "Replaced %s with %d imported keys.\n", describePath(under), len(importedValues(imported, nil)))
+	} else {
+		var added, changed, unchanged int
+		var conflicts []importConflict
+		for _, leaf := range importedValues(imported, under) {
+			current, found, conflict := lookupForImport(existing, leaf.Path)
+			switch {
+			case conflict != "":
+				conflicts = append(conflicts, importConflict{Path: leaf.Key(), Reason: conflict})
+			case !found:
+				added++
+			case sameValue(current, leaf.Value):
+				unchanged++
+				continue
+			default:
+				conflicts = append(conflicts, importConflict{Path: leaf.Key(), Reason: "already set to a different value"})
+			}
+
+			err = setNode(doc, leaf.Path, leaf.Value)
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, c := range conflicts {
+			_, _ = fmt.Fprintf(os.Stderr, "conflict: %s: %s\n", c.Path, c.Reason)
+		}
+		if len(conflicts) > 0 && !cmd.Overwrite {
+			return fmt.Errorf("%d conflicting keys, use --overwrite or --replace to import them anyway", len(conflicts))
+		}
+		changed = len(conflicts)
+		_, _ = fmt.Fprintf(os.Stderr, "Imported %d keys: %d added, %d changed, %d unchanged.\n", added+changed+unchanged, added, changed, unchanged)
+	}
+
+	newRawString, err := credentials.EncodeContent(doc)
+	if err != nil {
+		return err
+	}
+	if newRawString == rawString {
+		return nil
+	}
+	return cli.saveCredentials(newRawString)
+}
+
+// load reads the file into a tree according to its format.
+func (cmd *Import) load(cli *Cli) (map[string]any, error) {
+	content, err := os.ReadFile(cmd.File)
+	if err != nil {
+		return nil, err
+	}
+
+	format := cmd.Format
+	if format == "auto" {
+		format = guessImportFormat(cmd.File)
+	}
+
+	switch format {
+	case "dotenv":
+		variables, err := parseDotenv(string(content))
+		if err != nil {
+			return nil, err
+		}
+		return cmd.unflatten(variables)
+
+	case "json":
+		var v any
+		d := json.NewDecoder(bytes.NewReader(content))
+		d.UseNumber()
+		err = d.Decode(&v)
+		if err != nil {
+			return nil, err
+		}
+		tree, ok := normalizeJSON(v).(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("JSON must be an object, got %T", v)
+		}
+		return tree, nil
+
+	case "yaml":
+		return credentials.ParseContent(string(content))
+
+	case "secrets":
+		environment := cmd.SecretsEnvironment
+		if environment == "" {
+			environment = cli.Environment
+		}
+		if environment == "" {
+			return nil, fmt.Errorf("--secrets-environment or --environment is required to import secrets.yml")
+		}
+		expanded, unresolved, err := credentials.ExpandSecretsERB(string(content), os.LookupEnv)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range unresolved {
+			_, _ = fmt.Fprintf(os.Stderr, "warning: environment variable %s is not set, imported as empty\n", name)
+		}
+		return credentials.ParseSecrets(expanded, environment)
+
+	default:
+		return nil, fmt.Errorf("unknown format %s", format)
+	}
+}
+
+func guessImportFormat(file string) string {
+	base := strings.ToLower(filepath.Base(file))
+	switch {
+	case strings.HasSuffix(base, ".json"):
+		return "json"
+	case strings.HasPrefix(base, "secrets") && (strings.HasSuffix(base, ".yml") || strings.HasSuffix(base, ".yaml")):
+		return "secrets"
+	case strings.HasSuffix(base, ".yml") || strings.HasSuffix(base, ".yaml"):
+		return "yaml"
+	default:
+		return "dotenv"
+	}
+}
+
+// unflatten turns variables like AWS__ACCESS_KEY_ID into nested keys like aws.access_key_id.
+func (cmd *Import) unflatten(variables []envVariable) (map[string]any, error) {
+	ret := map[string]any{}
+	for _, v := range variables {
+		name, ok := strings.CutPrefix(v.Name, cmd.Prefix)
+		if !ok || name == "" {
+			continue
+		}
+		if !cmd.KeepCase {
+			name = strings.ToLower(name)
+		}
+
+		var path []string
+		if cmd.Separator == "" {
+			path = []string{name}
+		} else {
+			path = strings.Split(name, cmd.Separator)
+		}
+
+		node := ret
+		for i, segment := range path[:len(path)-1] {
+			child, ok := node[segment]
+			if !ok {
+				child = map[string]any{}
+				node[segment] = child
+			}
+			m, ok := child.(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("variable %s conflicts with %s", v.Name, strings.Join(path[:i+1], credentials.PathSeparator))
+			}
+			node = m
+		}
+
+		last := path[len(path)-1]
+		if _, ok := node[last]; ok {
+			return nil, fmt.Errorf("variable %s conflicts with another variable", v.Name)
+		}
+		node[last] = v.Value
+	}
+	return ret, nil
+}
+
+// dotenvERBMessage explains why a dotenv file with ERB tags, like the `<%-` and `-%>` trim forms of templates, is
+// rejected instead of importing the tags as values.
+const dotenvERBMessage = "ERB is not supported in dotenv files; render the template first, or quote the value to import it as is"
+
+// parseDotenv understands the common subset of the dotenv dialects: comments, `export` prefixes, unquoted, single
+// quoted (literal) and double-quoted (escaped, possibly multi-line) values. ERB tags are rejected outside of quotes.
+func parseDotenv(content string) ([]envVariable, error) {
+	var ret []envVariable
+	s := bufio.NewScanner(strings.NewReader(content))
+	lineNumber := 0
+	for s.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		if strings.HasPrefix(line, "<%") {
+			return nil, fmt.Errorf("line %d: %s", lineNumber, dotenvERBMessage)
+		}
+
+		name, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("line %d: missing '='", lineNumber)
+		}
+		name = strings.TrimSpace(name)
+		value = strings.TrimSpace(value)
+
+		switch {
+		case strings.HasPrefix(value, "'"):
+			end := strings.Index(value[1:], "'")
+			if end < 0 {
+				return nil, fmt.Errorf("line %d: unterminated single quote", lineNumber)
+			}
+			value = value[1 : end+1]
+
+		case strings.HasPrefix(value, `"`):
+			raw := value[1:]
+			for !closedDoubleQuote(raw) {
+				if !s.Scan() {
+					return nil, fmt.Errorf("line %d: unterminated double quote", lineNumber)
+				}
+				lineNumber++
+				raw += "\n" + s.Text()
+			}
+			value = unescapeDoubleQuoted(raw)
+
+		default:
+			if i := strings.Index(value, " #"); i >= 0 {
+				value = strings.TrimSpace(value[:i])
+			}
+			if strings.Contains(value, "<%") {
+				return nil, fmt.Errorf("line %d: %s", lineNumber, dotenvERBMessage)
+			}
+		}
+
+		ret = append(ret, envVariable{Name: name, Value: value})
+	}
+	return ret, s.Err()
+}
+
+// closedDoubleQuote reports whether s contains an unescaped double quote.
+func closedDoubleQuote(s string) bool {
+	escaped := false
+	for _, r := range s {
+		switch {
+		case escaped:
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == '"':
+			return true
+		}
+	}
+	return false
+}
+
+// unescapeDoubleQuoted decodes a double-quoted value up to its closing quote.
+func unescapeDoubleQuoted(s string) string {
+	var b strings.Builder
+	escaped := false
+	for _, r := range s {
+		if escaped {
+			escaped = false
+			switch r {
+			case 'n':
+				b.WriteRune('\n')
+			case 'r':
+				b.WriteRune('\r')
+			case 't':
+				b.WriteRune('\t')
+			default:
+				b.WriteRune(r)
+			}
+			continue
+		}
+		switch r {
+		case '\\':
+			escaped = true
+		case '"':
+			return b.String()
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+func normalizeJSON(v any) any {
+	switch n := v.(type) {
+	case map[string]any:
+		for k, child := range n {
+			n[k] = normalizeJSON(child)
+		}
+		return n
+	case []any:
+		for i, child := range n {
+			n[i] = normalizeJSON(child)
+		}
+		return n
+	case json.Number:
+		if i, err := n.Int64(); err == nil {
+			return i
+		}
+		if f, err := n.Float64(); err == nil {
+			return f
+		}
+		return n.String()
+	default:
+		return n
+	}
+}
+
+// importedValues lists the values to import. Unlike credentials.Leaves, sequences are kept as a whole, since merging
+// them item by item would produce surprising results.
+func importedValues(tree map[string]any, prefix []string) []credentials.Leaf {
+	keys := make([]string, 0, len(tree))
+	for k := range tree {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var ret []credentials.Leaf
+	for _, k := range keys {
+		path := append(append([]string{}, prefix...), k)
+		if m, ok := tree[k].(map[string]any); ok && len(m) > 0 {
+			ret = append(ret, importedValues(m, path)...)
+		} else {
+			ret = append(ret, credentials.Leaf{Path: path, Value: tree[k]})
+		}
+	}
+	return ret
+}
+
+// lookupForImport finds the existing value at path. conflict describes why the path cannot be set without
+// overwriting an existing value of a different shape.
+func lookupForImport(tree map[string]any, path []string) (value any, found bool, conflict string) {
+	value = tree
+	for i, segment := range path {
+		m, ok := value.(map[string]any)
+		if !ok {
+			return nil, false, fmt.Sprintf("%s is not a mapping", strings.Join(path[:i], credentials.PathSeparator))
+		}
+		value, found = m[segment]
+		if !found {
+			return nil, false, ""
+		}
+	}
+	if _, ok := value.(map[string]any); ok {
+		return value, true, "is a mapping in the existing credentials"
+	}
+	return value, true, ""
+}
+
+func sameValue(a, b any) bool {
+	if reflect.DeepEqual(a, b) {
+		return true
+	}
+	_, aComposite := a.(map[string]any)
+	_, bComposite := b.(map[string]any)
+	if aComposite || bComposite {
+		return false
+	}
+	_, aComposite = a.([]any)
+	_, bComposite = b.([]any)
+	if aComposite || bComposite {
+		return false
+	}
+	return credentials.FormatScalar(a) == credentials.FormatScalar(b)
+}
+
+func describePath(path []string) string {
+	if len(path) == 0 {
+		return "the credentials"
+	}
+	return strings.Join(path, credentials.PathSeparator)
+}
+
+// parseDocument parses the plaintext into a YAML node tree, which keeps the comments when written back.
+func parseDocument(content string) (*yaml.Node, error) {
+	var doc yaml.Node
+	err := yaml.Unmarshal([]byte(content), &doc)
+	if err != nil {
+		return nil, fmt.Errorf("parse YAML failed: %w", err)
+	}
+	if doc.Kind == 0 {
+		doc.Kind = yaml.DocumentNode
+	}
+	if len(doc.Content) == 0 || (doc.Content[0].Kind == yaml.ScalarNode && doc.Content[0].Tag == "!!null") {
+		doc.Content = []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}
+	}
+	if doc.Content[0].Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("credentials must be a YAML mapping")
+	}
+	return &doc, nil
+}
+
+// setNode sets the value at path, creating intermediate mappings and replacing anything in the way.
+func setNode(doc *yaml.Node, path []string, value any) error {
+	if len(path) == 0 {
+		return fmt.Errorf("empty key path")
+	}
+
+	node := doc.Content[0]
+	for _, segment := range path[:len(path)-1] {
+		child := mappingValue(node, segment)
+		if child == nil {
+			child = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+			appendMapping(node, segment, child)
+		} else if child.Kind != yaml.MappingNode {
+			*child = yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		}
+		node = child
+	}
+
+	v := &yaml.Node{}
+	err := v.Encode(value)
+	if err != nil {
+		return fmt.Errorf("encode %s failed: %w", strings.Join(path, credentials.PathSeparator), err)
+	}
+
+	last := path[len(path)-1]
+	if child := mappingValue(node, last); child != nil {
+		// keep the comments around the key
+		v.HeadComment, v.LineComment, v.FootComment = child.HeadComment, child.LineComment, child.FootComment
+		*child = *v
+	} else {
+		appendMapping(node, last, v)
+	}
+	return nil
+}
+
+// replaceNode replaces the value at path, or the whole document if path is empty.
+func replaceNode(doc *yaml.Node, path []string, value map[string]any) error {
+	if len(path) > 0 {
+		return setNode(doc, path, value)
+	}
+
+	v := &yaml.Node{}
+	err := v.Encode(value)
+	if err != nil {
+		return fmt.Errorf("encode credentials failed: %w", err)
+	}
+	doc.Content = []*yaml.Node{v}
+	return nil
+}
+
+func mappingValue(mapping *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return mapping.Content[i+1]
+		}
+	}
+	return nil
+}
+
+func appendMapping(mapping *yaml.Node, key string, value *yaml.Node) {
+	mapping.Content = append(mapping.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, value)
+}
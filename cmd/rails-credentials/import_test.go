@@ -0,0 +1,26 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestParseDotenv(t *testing.T) {
+	variables, err := parseDotenv(`
+# comment
+export A=1 # trailing comment
+B='<%= literal %>'
+C="multi
+line"
+`)
+	assert.NoError(t, err)
+	assert.Equal(t, []envVariable{{Name: "A", Value: "1"}, {Name: "B", Value: "<%= literal %>"}, {Name: "C", Value: "multi\nline"}}, variables)
+
+	for _, content := range []string{
+		"<%- if Rails.env.production? -%>\nA=1\n<%- end -%>\n",
+		"A=<%= ENV['A'] %>\n",
+	} {
+		_, err = parseDotenv(content)
+		assert.ErrorContains(t, err, "ERB is not supported")
+	}
+}
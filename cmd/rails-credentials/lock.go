@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const lockedTemplate = "%s is being edited in another session (pid %s); try again when it is done, or delete %s if that session is gone"
+
+// credentialsLock is an advisory lock held for the whole edit session of a credentials file, so two sessions can not
+// overwrite each other. The lock file sits next to the credentials file and contains the pid of the holder.
+type credentialsLock struct {
+	path   string
+	target string
+	file   *os.File
+}
+
+// lockCredentials takes the lock of a credentials file, failing right away if another session holds it.
+func lockCredentials(target string) (*credentialsLock, error) {
+	l := &credentialsLock{path: target + ".lock", target: target}
+	err := os.MkdirAll(filepath.Dir(l.path), 0o777)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create directory: %w", err)
+	}
+	err = l.lock()
+	if err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func (l *credentialsLock) lockedError() error {
+	pid := "unknown"
+	if b, err := os.ReadFile(l.path); err == nil && len(strings.TrimSpace(string(b))) > 0 {
+		pid = strings.TrimSpace(string(b))
+	}
+	return fmt.Errorf(lockedTemplate, l.target, pid, l.path)
+}
+
+func (l *credentialsLock) writePid() error {
+	err := l.file.Truncate(0)
+	if err != nil {
+		return err
+	}
+	_, err = l.file.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+	return err
+}
@@ -0,0 +1,46 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+)
+
+func (l *credentialsLock) lock() error {
+	for {
+		f, err := os.OpenFile(l.path, os.O_RDWR|os.O_CREATE, 0o600)
+		if err != nil {
+			return fmt.Errorf("unable to create lock file: %w", err)
+		}
+		err = syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			_ = f.Close()
+			return l.lockedError()
+		} else if err != nil {
+			_ = f.Close()
+			return fmt.Errorf("unable to lock %s: %w", l.path, err)
+		}
+
+		// the previous holder removes the lock file when it is done; if that happened between our open and flock,
+		// we locked a file nobody else can see, so start over with a new one
+		opened, err1 := f.Stat()
+		current, err2 := os.Stat(l.path)
+		if err1 != nil || err2 != nil || !os.SameFile(opened, current) {
+			_ = f.Close()
+			continue
+		}
+
+		l.file = f
+		return l.writePid()
+	}
+}
+
+// Unlock removes the lock file while still holding the lock, then releases it. The kernel releases the lock if the
+// process dies, so a lock file left behind by a crash does not block anyone.
+func (l *credentialsLock) Unlock() {
+	_ = os.Remove(l.path)
+	_ = l.file.Close()
+}
@@ -0,0 +1,41 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd)
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// lock creates the lock file exclusively, since flock(2) is not available. A lock file whose holder is no longer
+// running is stale and is taken over.
+func (l *credentialsLock) lock() error {
+	for attempt := 0; ; attempt++ {
+		f, err := os.OpenFile(l.path, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0o600)
+		if err == nil {
+			l.file = f
+			return l.writePid()
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return fmt.Errorf("unable to create lock file: %w", err)
+		}
+
+		b, err := os.ReadFile(l.path)
+		if err != nil || attempt > 0 {
+			return l.lockedError()
+		}
+		pid, err := strconv.Atoi(strings.TrimSpace(string(b)))
+		if err == nil && processRunning(pid) {
+			return l.lockedError()
+		}
+		_ = os.Remove(l.path)
+	}
+}
+
+func (l *credentialsLock) Unlock() {
+	_ = l.file.Close()
+	_ = os.Remove(l.path)
+}
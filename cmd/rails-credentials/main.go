@@ -3,11 +3,11 @@
 package main
 
 import (
+	"bufio"
 	"fmt"
 	"github.com/alecthomas/kong"
 	"github.com/jamesits/go-rails-credentials/pkg/credentials"
 	"os"
-	"path/filepath"
 	"strings"
 )
 
@@ -15,17 +15,63 @@ func executable(s ...string) string {
 	return strings.Join(append([]string{os.Args[0]}, s...), " ")
 }
 
+var stdin = bufio.NewReader(os.Stdin)
+
+// confirm asks a yes/no question on the terminal. The answer defaults to yes; end of input means no.
+func confirm(prompt string) bool {
+	_, _ = fmt.Fprint(os.Stderr, prompt)
+	answer, err := stdin.ReadString('\n')
+	if err != nil && answer == "" {
+		_, _ = fmt.Fprintln(os.Stderr)
+		return false
+	}
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "", "y", "yes":
+		return true
+	default:
+		return false
+	}
+}
+
 type Cli struct {
-	Edit Edit "cmd:\"\" help:\"Open the decrypted credentials in `$VISUAL` or `$EDITOR` for editing\""
-	Show Show `cmd:"" help:"Show the decrypted credentials"`
+	Edit   Edit   "cmd:\"\" help:\"Open the decrypted credentials in `$VISUAL` or `$EDITOR` for editing\""
+	Show   Show   `cmd:"" help:"Show the decrypted credentials"`
+	Export Export `cmd:"" help:"Print the decrypted credentials as environment variables"`
+	Exec   Exec   `cmd:"" help:"Run a command with the decrypted credentials in its environment"`
+	Import Import `cmd:"" help:"Import values from a dotenv, JSON, YAML or secrets.yml file into the credentials"`
+
+	Validate Validate `cmd:"" help:"Check the decrypted credentials against the schema"`
+	Verify   Verify   `cmd:"" help:"Check that every credentials file in the project can be decrypted, for CI"`
+	Doctor   Doctor   `cmd:"" help:"Check the key and credentials files for common mistakes"`
+	Backups  Backups  `cmd:"" help:"List, inspect and restore previous versions of the credentials"`
+	Diff     Diff     `cmd:"" help:"Compare the credentials between git revisions, key by key"`
+	History  History  `cmd:"" help:"List the commits that changed the credentials, key by key"`
+
+	MigrateSecrets MigrateSecrets `cmd:"" help:"Split Rails 5.1 encrypted secrets into per-environment credentials"`
 
 	BaseDir                  string `name:"base-dir" default:"." type:"existingdir" help:"Root directory of your Rails project."`
 	Environment              string `name:"environment" env:"RAILS_ENV"`
 	MasterKey                string `name:"master-key" env:"RAILS_MASTER_KEY" help:"Your master key. For security, please do not provide this value by CLI argument; use the environment variable or a file instead."`
 	MasterKeyFile            string `name:"master-key-file" help:"Path to your master.key file."`
 	EncryptedCredentialsFile string `name:"credentials-file" help:"Path to your credential.yml.enc file."`
+	SchemaFile               string `name:"schema-file" help:"Path to the schema the credentials are validated against (default: config/credentials.schema.json)."`
+	KeepBackups              int    `name:"keep-backups" env:"RAILS_CREDENTIALS_BACKUPS" default:"10" help:"Number of previous versions of each credentials file to keep in tmp/credentials-backups; 0 disables backups."`
 
-	masterKeyGenerated bool
+	masterKeySource      string
+	masterKeyGenerated   bool
+	masterKeyFileWritten bool
+}
+
+// exitStatus is returned by commands that need to exit with a specific status, after they printed the details
+// themselves. Like verifyError, kong exits with its ExitCode.
+type exitStatus int
+
+func (e exitStatus) Error() string {
+	return fmt.Sprintf("exit status %d", int(e))
+}
+
+func (e exitStatus) ExitCode() int {
+	return int(e)
 }
 
 func (cli *Cli) AfterApply() error {
@@ -35,29 +81,26 @@ func (cli *Cli) AfterApply() error {
 	}
 
 	// parse RAILS_ENV
-	if cli.Environment == "" {
-		if cli.MasterKeyFile == "" {
-			cli.MasterKeyFile = filepath.Join("config", "master.key")
-		}
-		if cli.EncryptedCredentialsFile == "" {
-			cli.EncryptedCredentialsFile = filepath.Join("config", "credentials.yml.enc")
-		}
-	} else {
-		if cli.MasterKeyFile == "" {
-			cli.MasterKeyFile = filepath.Join("config", "credentials", fmt.Sprintf("%s.key", cli.Environment))
-		}
-		if cli.EncryptedCredentialsFile == "" {
-			cli.EncryptedCredentialsFile = filepath.Join("config", "credentials", fmt.Sprintf("%s.yml.enc", cli.Environment))
-		}
+	masterKeyFile, encryptedCredentialsFile := credentials.DefaultPaths(cli.Environment)
+	if cli.MasterKeyFile == "" {
+		cli.MasterKeyFile = masterKeyFile
+	}
+	if cli.EncryptedCredentialsFile == "" {
+		cli.EncryptedCredentialsFile = encryptedCredentialsFile
+	}
+	if cli.SchemaFile == "" {
+		cli.SchemaFile = credentials.SchemaFile
 	}
 
 	cli.MasterKey = credentials.SanitizeMasterKey(cli.MasterKey)
+	cli.masterKeySource = "RAILS_MASTER_KEY"
 	// If RAILS_MASTER_KEY environment variable is set, we use it instead of the file content.
 	// Otherwise, try read an existing master key.
 	if cli.MasterKey == "" {
 		m, err := os.ReadFile(cli.MasterKeyFile)
 		if err == nil {
 			cli.MasterKey = credentials.SanitizeMasterKey(string(m))
+			cli.masterKeySource = cli.MasterKeyFile
 		} else if !os.IsNotExist(err) {
 			return fmt.Errorf("unable to read master key file %s: %w", cli.MasterKeyFile, err)
 		}
@@ -74,6 +117,31 @@ func (cli *Cli) AfterApply() error {
 	return nil
 }
 
+// readCredentials reads and decrypts the credentials file, printing the same hints as Rails on failure.
+func (cli *Cli) readCredentials() (string, error) {
+	e, err := os.ReadFile(cli.EncryptedCredentialsFile)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, missingCredentialsMessageTemplate, cli.EncryptedCredentialsFile, executable("edit"))
+		return "", fmt.Errorf("read encrypted file failed: %w", err)
+	}
+
+	rawObject, err := credentials.Decrypt(cli.MasterKey, string(e))
+	if err != nil {
+		if cli.masterKeyGenerated {
+			_, _ = fmt.Fprintf(os.Stderr, missingKeyMessageTemplate, cli.MasterKeyFile, executable("--help"))
+		} else {
+			_, _ = fmt.Fprintf(os.Stderr, decryptFailedTemplate, cli.EncryptedCredentialsFile)
+		}
+		return "", fmt.Errorf("decrypt failed: %w", err)
+	}
+
+	rawString, err := credentials.UnmarshalSingleString(rawObject)
+	if err != nil {
+		return "", fmt.Errorf("unmarshal failed: %w", err)
+	}
+	return rawString, nil
+}
+
 func main() {
 	cli := &Cli{}
 	ctx := kong.Parse(cli)
@@ -0,0 +1,18 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/alecthomas/kong"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestExitStatus(t *testing.T) {
+	// kong exits with the code of any error that has one, also when wrapped
+	var coder kong.ExitCoder
+	assert.True(t, errors.As(fmt.Errorf("wrapped: %w", exitStatus(3)), &coder))
+	assert.Equal(t, 3, coder.ExitCode())
+	assert.True(t, errors.As(verifyError{failures: 2}, &coder))
+	assert.Equal(t, 2, coder.ExitCode())
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/jamesits/go-rails-credentials/pkg/credentials"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+const (
+	missingSecretsKeyMessage = "Missing encryption key to decrypt secrets with. Ask your team for your master key and put it in ENV[\"RAILS_MASTER_KEY\"]\n"
+	migratedHeaderTemplate   = "# Migrated from %s (sections: %s).\n\n"
+	migrationNextSteps       = `
+Next steps:
+  - Make sure config/credentials/*.key is in .gitignore, and share the new keys with your team.
+  - Deploy each environment with RAILS_MASTER_KEY set to the content of its key file.
+  - Replace Rails.application.secrets with Rails.application.credentials in your code.
+  - Delete %s and its key once everything works.
+`
+)
+
+type MigrateSecrets struct {
+	SecretsFile    string   `name:"secrets-file" placeholder:"PATH" help:"Path to your secrets.yml.enc, or a plaintext secrets.yml (default: config/secrets.yml.enc)."`
+	SecretsKeyFile string   `name:"secrets-key-file" placeholder:"PATH" help:"Path to your secrets.yml.key (default: config/secrets.yml.key)."`
+	SecretsKey     string   `name:"secrets-key" env:"RAILS_MASTER_KEY" help:"Key of the encrypted secrets. For security, please do not provide this value by CLI argument; use the environment variable or a file instead."`
+	Environments   []string `name:"environments" sep:"," placeholder:"ENV" help:"Only migrate these environments."`
+	Force          bool     `name:"force" help:"Overwrite existing per-environment credentials and keys."`
+	DryRun         bool     `name:"dry-run" help:"Only print the migration report."`
+}
+
+type secretsMigration struct {
+	Environment string
+	Keys        int
+	Files       []string
+	Skipped     string
+}
+
+func (cmd *MigrateSecrets) Run(cli *Cli) error {
+	if cmd.SecretsFile == "" {
+		cmd.SecretsFile = credentials.EncryptedSecretsFile
+	}
+	if cmd.SecretsKeyFile == "" {
+		cmd.SecretsKeyFile = credentials.EncryptedSecretsKeyFile
+	}
+
+	rawString, err := cmd.read()
+	if err != nil {
+		return err
+	}
+
+	// Rails renders secrets with ERB after decryption
+	expanded, unresolved, err := credentials.ExpandSecretsERB(rawString, os.LookupEnv)
+	if err != nil {
+		return fmt.Errorf("render %s failed: %w", cmd.SecretsFile, err)
+	}
+
+	environments := cmd.Environments
+	if len(environments) == 0 {
+		environments, err = credentials.SecretsEnvironments(expanded)
+		if err != nil {
+			return fmt.Errorf("parse %s failed: %w", cmd.SecretsFile, err)
+		}
+	}
+	if len(environments) == 0 {
+		return fmt.Errorf("no environments found in %s", cmd.SecretsFile)
+	}
+
+	var migrations []secretsMigration
+	for _, environment := range environments {
+		m, err := cmd.migrate(cli, expanded, environment)
+		if err != nil {
+			return fmt.Errorf("migrate %s failed: %w", environment, err)
+		}
+		migrations = append(migrations, m)
+	}
+
+	// report
+	if cmd.DryRun {
+		_, _ = fmt.Fprintf(os.Stdout, "Migration plan for %s (dry run, nothing written):\n\n", cmd.SecretsFile)
+	} else {
+		_, _ = fmt.Fprintf(os.Stdout, "Migrated %s:\n\n", cmd.SecretsFile)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	for _, m := range migrations {
+		result := strings.Join(m.Files, ", ")
+		if m.Skipped != "" {
+			result = "skipped, " + m.Skipped
+		}
+		_, _ = fmt.Fprintf(w, "  %s\t%d keys\t%s\n", m.Environment, m.Keys, result)
+	}
+	_ = w.Flush()
+	if len(unresolved) > 0 {
+		_, _ = fmt.Fprintf(os.Stdout, "\nEnvironment variables not set, migrated as empty values: %s\n", strings.Join(unresolved, ", "))
+	}
+	_, _ = fmt.Fprintf(os.Stdout, migrationNextSteps, cmd.SecretsFile)
+	return nil
+}
+
+// read returns the plaintext secrets.yml, decrypting it if necessary.
+func (cmd *MigrateSecrets) read() (string, error) {
+	content, err := os.ReadFile(cmd.SecretsFile)
+	if err != nil {
+		return "", fmt.Errorf("read secrets file failed: %w", err)
+	}
+	if !strings.HasSuffix(cmd.SecretsFile, ".enc") {
+		return string(content), nil
+	}
+
+	// like Rails::Secrets.key, RAILS_MASTER_KEY takes precedence over the key file
+	key := credentials.SanitizeMasterKey(cmd.SecretsKey)
+	if key == "" {
+		k, err := os.ReadFile(cmd.SecretsKeyFile)
+		if errors.Is(err, os.ErrNotExist) {
+			_, _ = fmt.Fprint(os.Stderr, missingSecretsKeyMessage)
+			return "", fmt.Errorf("read secrets key file failed: %w", err)
+		} else if err != nil {
+			return "", fmt.Errorf("read secrets key file failed: %w", err)
+		}
+		key = credentials.SanitizeMasterKey(string(k))
+	}
+
+	rawObject, err := credentials.Decrypt(key, string(content))
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, decryptFailedTemplate, cmd.SecretsFile)
+		return "", fmt.Errorf("decrypt failed: %w", err)
+	}
+	rawString, err := credentials.UnmarshalSingleString(rawObject)
+	if err != nil {
+		return "", fmt.Errorf("unmarshal failed: %w", err)
+	}
+	return rawString, nil
+}
+
+// migrate writes the secrets of one environment into its own credentials file, encrypted with a new key.
+func (cmd *MigrateSecrets) migrate(cli *Cli, expanded string, environment string) (secretsMigration, error) {
+	m := secretsMigration{Environment: environment}
+
+	secrets, err := credentials.ParseSecrets(expanded, environment)
+	if err != nil {
+		return m, err
+	}
+	m.Keys = len(credentials.Leaves(secrets))
+
+	masterKeyFile, encryptedCredentialsFile := credentials.DefaultPaths(environment)
+	m.Files = []string{encryptedCredentialsFile, masterKeyFile}
+	if !cmd.Force {
+		for _, f := range m.Files {
+			if _, err := os.Stat(f); err == nil {
+				m.Skipped = fmt.Sprintf("%s exists (use --force to overwrite)", f)
+				return m, nil
+			}
+		}
+	}
+	if cmd.DryRun {
+		return m, nil
+	}
+
+	body, err := credentials.EncodeContent(secrets)
+	if err != nil {
+		return m, err
+	}
+	content := fmt.Sprintf(migratedHeaderTemplate, cmd.SecretsFile, strings.Join([]string{credentials.SecretsSharedSection, environment}, ", ")) + body
+
+	// with --force, the previous file is kept in the backups, although it can only be read with the old key
+	err = cli.backupFile(environment, encryptedCredentialsFile)
+	if err != nil {
+		return m, err
+	}
+	masterKey, err := credentials.RandomMasterKey()
+	if err != nil {
+		return m, fmt.Errorf("unable to generate a master key: %w", err)
+	}
+	err = credentials.WriteFile(masterKeyFile, []byte(masterKey), 0o600)
+	if err != nil {
+		return m, fmt.Errorf("write master key file failed: %w", err)
+	}
+	err = credentials.WriteEncryptedFile(encryptedCredentialsFile, masterKey, content)
+	if err != nil {
+		return m, fmt.Errorf("unable to save encrypted file: %w", err)
+	}
+	return m, nil
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/jamesits/go-rails-credentials/pkg/credentials"
+	"strings"
+	"unicode/utf8"
+)
+
+// Redaction modes, used by every command that can hide values:
+//   - hash: a keyed hash, so equal values can be recognized without being revealed; the key is derived from the
+//     master key, so the hash can not be brute-forced by someone who does not have it
+//   - length: the type, and the length of strings
+//   - ends: the first and last two characters of long strings, and asterisks for anything else
+const (
+	redactHash   = "hash"
+	redactLength = "length"
+	redactEnds   = "ends"
+
+	// redactEndsMinLength is the shortest string whose ends are shown; shorter ones would be mostly revealed
+	redactEndsMinLength = 12
+)
+
+type redactor struct {
+	mode string
+	key  []byte
+}
+
+func newRedactor(mode string, masterKey string) redactor {
+	mac := hmac.New(sha256.New, []byte(masterKey))
+	mac.Write([]byte("rails-credentials redaction"))
+	return redactor{mode: mode, key: mac.Sum(nil)}
+}
+
+// value returns the redacted form of a scalar.
+func (r redactor) value(v any) string {
+	if v == nil {
+		return "null"
+	}
+	s := credentials.FormatScalar(v)
+	_, isString := v.(string)
+
+	switch r.mode {
+	case redactHash:
+		mac := hmac.New(sha256.New, r.key)
+		// the type is part of the hash, so 1 and "1" differ
+		_, _ = fmt.Fprintf(mac, "%T:%s", v, s)
+		return "hmac:" + hex.EncodeToString(mac.Sum(nil))[:12]
+	case redactEnds:
+		n := utf8.RuneCountInString(s)
+		if !isString || n < redactEndsMinLength {
+			return strings.Repeat("*", min(n, 8))
+		}
+		runes := []rune(s)
+		return string(runes[:2]) + "…" + string(runes[n-2:])
+	default:
+		if isString {
+			return fmt.Sprintf("string, %d characters", utf8.RuneCountInString(s))
+		}
+		return scalarTypeName(v)
+	}
+}
+
+// scalarTypeName names the type of a YAML scalar.
+func scalarTypeName(v any) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case int, int64, uint64:
+		return "integer"
+	case float64:
+		return "float"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
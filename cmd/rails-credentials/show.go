@@ -1,9 +1,14 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
 	"github.com/jamesits/go-rails-credentials/pkg/credentials"
+	"io"
 	"os"
+	"sort"
+	"strconv"
+	"strings"
 )
 
 const (
@@ -11,34 +16,127 @@ const (
 	missingCredentialsMessageTemplate = "File '%s' does not exist. Use \"%s` to change that.\n"
 )
 
-type Show struct{}
+type Show struct {
+	Format     string   `name:"format" enum:"yaml,json,tree,flat" default:"yaml" help:"Output format, one of: ${enum}."`
+	Redact     bool     `name:"redact" help:"Mask every value, to see what is in the credentials without revealing it."`
+	RedactMode string   `name:"redact-mode" enum:"length,ends,hash" default:"length" help:"How values are masked, one of: ${enum}."`
+	Paths      []string `name:"paths" sep:"," placeholder:"PATH" help:"Only show the keys under these paths, e.g. aws,smtp."`
+}
 
 func (cmd *Show) Run(cli *Cli) error {
-	var err error
+	rawString, err := cli.readCredentials()
+	if err != nil {
+		return err
+	}
 
-	var rawObject []byte
+	// the file as written, with its comments and key order
+	if cmd.Format == "yaml" && !cmd.Redact && len(cmd.Paths) == 0 {
+		_, _ = fmt.Fprint(os.Stdout, rawString)
+		return nil
+	}
 
-	e, err := os.ReadFile(cli.EncryptedCredentialsFile)
+	tree, err := credentials.ParseContent(rawString)
 	if err != nil {
-		_, _ = fmt.Fprintf(os.Stderr, missingCredentialsMessageTemplate, cli.EncryptedCredentialsFile, executable("edit"))
-		return fmt.Errorf("read encrypted file failed: %w", err)
+		return err
+	}
+	var node any = tree
+	if len(cmd.Paths) > 0 {
+		var ok bool
+		node, ok = credentials.FilterTree(tree, parsePaths(cmd.Paths))
+		if !ok {
+			return fmt.Errorf("no credentials found under %s", strings.Join(cmd.Paths, ", "))
+		}
+	}
+	if cmd.Redact {
+		node = redactTree(node, newRedactor(cmd.RedactMode, cli.MasterKey))
 	}
 
-	rawObject, err = credentials.Decrypt(cli.MasterKey, string(e))
-	if err != nil {
-		if cli.masterKeyGenerated {
-			_, _ = fmt.Fprintf(os.Stderr, missingKeyMessageTemplate, cli.MasterKeyFile, executable("--help"))
-		} else {
-			_, _ = fmt.Fprintf(os.Stderr, decryptFailedTemplate, cli.EncryptedCredentialsFile)
+	switch cmd.Format {
+	case "json":
+		e := json.NewEncoder(os.Stdout)
+		e.SetIndent("", "  ")
+		return e.Encode(node)
+	case "tree":
+		return writeTree(os.Stdout, node, "", true)
+	case "flat":
+		for _, l := range credentials.Leaves(node) {
+			_, err = fmt.Fprintf(os.Stdout, "%s: %s\n", l.Key(), credentials.FormatScalar(l.Value))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		s, err := credentials.EncodeContent(node)
+		if err != nil {
+			return err
 		}
-		return fmt.Errorf("decrypt failed: %w", err)
+		_, _ = fmt.Fprint(os.Stdout, s)
+		return nil
 	}
+}
 
-	rawString, err := credentials.UnmarshalSingleString(rawObject)
-	if err != nil {
-		return fmt.Errorf("unmarshal failed: %w", err)
+// redactTree replaces every scalar in the tree with its redacted form.
+func redactTree(node any, r redactor) any {
+	switch n := node.(type) {
+	case map[string]any:
+		ret := make(map[string]any, len(n))
+		for k, v := range n {
+			ret[k] = redactTree(v, r)
+		}
+		return ret
+	case []any:
+		ret := make([]any, len(n))
+		for i, v := range n {
+			ret[i] = redactTree(v, r)
+		}
+		return ret
+	default:
+		return r.value(n)
+	}
+}
+
+// writeTree prints the tree like tree(1), with the values next to their keys. The top level keys are the roots.
+func writeTree(w io.Writer, node any, indent string, root bool) error {
+	type entry struct {
+		name  string
+		value any
+	}
+	var entries []entry
+	switch n := node.(type) {
+	case map[string]any:
+		for k, v := range n {
+			entries = append(entries, entry{k, v})
+		}
+		sort.Slice(entries, func(i, j int) bool { return entries[i].name < entries[j].name })
+	case []any:
+		for i, v := range n {
+			entries = append(entries, entry{strconv.Itoa(i), v})
+		}
 	}
 
-	_, _ = fmt.Fprint(os.Stdout, rawString)
+	for i, e := range entries {
+		branch, next := "├── ", "│   "
+		if i == len(entries)-1 {
+			branch, next = "└── ", "    "
+		}
+		if root {
+			branch, next = "", ""
+		}
+
+		var err error
+		switch e.value.(type) {
+		case map[string]any, []any:
+			_, err = fmt.Fprintf(w, "%s%s%s\n", indent, branch, e.name)
+			if err == nil {
+				err = writeTree(w, e.value, indent+next, false)
+			}
+		default:
+			_, err = fmt.Fprintf(w, "%s%s%s: %s\n", indent, branch, e.name, credentials.FormatScalar(e.value))
+		}
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"bytes"
+	"github.com/jamesits/go-rails-credentials/pkg/credentials"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestShowPaths(t *testing.T) {
+	tree, err := credentials.ParseContent("hosts: [a, b, c]\nsmtp:\n  user_name: user\n")
+	assert.NoError(t, err)
+	filtered, ok := credentials.FilterTree(tree, parsePaths([]string{"hosts.2", "smtp"}))
+	assert.True(t, ok)
+
+	// the kept item is shown with its index in the file
+	b := bytes.Buffer{}
+	assert.NoError(t, writeTree(&b, filtered, "", true))
+	assert.Equal(t, "hosts\n└── 2: c\nsmtp\n└── user_name: user\n", b.String())
+
+	var flat []string
+	for _, l := range credentials.Leaves(filtered) {
+		flat = append(flat, l.Key())
+	}
+	assert.Equal(t, []string{"hosts.2", "smtp.user_name"}, flat)
+}
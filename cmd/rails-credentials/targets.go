@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"github.com/jamesits/go-rails-credentials/pkg/credentials"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// credentialsTarget is an encrypted credentials file in the project, together with the environment it belongs to.
+type credentialsTarget struct {
+	Environment              string
+	MasterKeyFile            string
+	EncryptedCredentialsFile string
+}
+
+func (t credentialsTarget) String() string {
+	return t.EncryptedCredentialsFile
+}
+
+// discoverTargets lists the global credentials file and every per-environment credentials file that exist.
+func discoverTargets() ([]credentialsTarget, error) {
+	var ret []credentialsTarget
+
+	masterKeyFile, encryptedCredentialsFile := credentials.DefaultPaths("")
+	if _, err := os.Stat(encryptedCredentialsFile); err == nil {
+		ret = append(ret, credentialsTarget{MasterKeyFile: masterKeyFile, EncryptedCredentialsFile: encryptedCredentialsFile})
+	}
+
+	matches, err := filepath.Glob(filepath.Join(credentials.PerEnvironmentDir, "*.yml.enc"))
+	if err != nil {
+		return nil, fmt.Errorf("unable to list credentials files: %w", err)
+	}
+	sort.Strings(matches)
+	for _, m := range matches {
+		environment := strings.TrimSuffix(filepath.Base(m), ".yml.enc")
+		masterKeyFile, encryptedCredentialsFile = credentials.DefaultPaths(environment)
+		ret = append(ret, credentialsTarget{Environment: environment, MasterKeyFile: masterKeyFile, EncryptedCredentialsFile: encryptedCredentialsFile})
+	}
+	return ret, nil
+}
+
+// masterKey returns the key for a target and where it came from. The key is looked up in order from:
+//   - RAILS_<ENV>_KEY, e.g. RAILS_PRODUCTION_KEY for config/credentials/production.yml.enc
+//   - the key given by --master-key or RAILS_MASTER_KEY, if the target belongs to the current environment
+//   - the key file of the target
+//
+// An empty key means none was found.
+func (cli *Cli) masterKey(t credentialsTarget) (key string, source string, err error) {
+	if t.Environment != "" {
+		name := environmentKeyVariable(t.Environment)
+		if key = credentials.SanitizeMasterKey(os.Getenv(name)); key != "" {
+			return key, name, nil
+		}
+	}
+
+	if t.Environment == cli.Environment && !cli.masterKeyGenerated {
+		return cli.MasterKey, cli.masterKeySource, nil
+	}
+
+	m, err := os.ReadFile(t.MasterKeyFile)
+	if os.IsNotExist(err) {
+		return "", "", nil
+	} else if err != nil {
+		return "", "", fmt.Errorf("unable to read master key file %s: %w", t.MasterKeyFile, err)
+	}
+	return credentials.SanitizeMasterKey(string(m)), t.MasterKeyFile, nil
+}
+
+// environmentKeyVariable returns the name of the variable holding the key of an environment, e.g. RAILS_PRODUCTION_KEY.
+func environmentKeyVariable(environment string) string {
+	return "RAILS_" + strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9'):
+			return r
+		default:
+			return '_'
+		}
+	}, environment) + "_KEY"
+}
+
+// decryptTarget reads and decrypts a target with its master key.
+func (cli *Cli) decryptTarget(t credentialsTarget) (string, error) {
+	masterKey, _, err := cli.masterKey(t)
+	if err != nil {
+		return "", err
+	}
+	if masterKey == "" {
+		return "", fmt.Errorf("missing master key %s", t.MasterKeyFile)
+	}
+
+	e, err := os.ReadFile(t.EncryptedCredentialsFile)
+	if err != nil {
+		return "", fmt.Errorf("read encrypted file failed: %w", err)
+	}
+	rawObject, err := credentials.Decrypt(masterKey, string(e))
+	if err != nil {
+		return "", fmt.Errorf("decrypt failed: %w", err)
+	}
+	return credentials.UnmarshalSingleString(rawObject)
+}
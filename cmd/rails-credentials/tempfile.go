@@ -0,0 +1,178 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+)
+
+const (
+	plaintextDirPrefix   = "rails-credentials-"
+	plaintextFileName    = "credentials.yml"
+	leftoverWarnTemplate = "Warning: %s was left behind by an edit session that did not exit cleanly and may contain plaintext credentials. Delete it once you have recovered anything you need.\n"
+)
+
+// plaintextFile is the decrypted credentials file handed to the editor. It lives alone in a private directory,
+// preferably on a memory-backed file system, so the plaintext never reaches the disk and other users can not read it.
+type plaintextFile struct {
+	Path string
+	dir  string
+	once sync.Once
+}
+
+// plaintextBaseDirs returns the directories to create the plaintext file in, most preferred first. $XDG_RUNTIME_DIR
+// and /dev/shm are memory-backed on Linux; the system temporary directory is the fallback.
+func plaintextBaseDirs() []string {
+	var ret []string
+	if d := os.Getenv("XDG_RUNTIME_DIR"); d != "" {
+		ret = append(ret, d)
+	}
+	ret = append(ret, "/dev/shm", os.TempDir())
+	return ret
+}
+
+// createPlaintextFile writes the content into a 0600 file inside a new 0700 directory. The directory name contains
+// our pid, so a later run can tell whether the session that created it is still alive.
+func createPlaintextFile(content string) (*plaintextFile, error) {
+	var dir string
+	var err error
+	for _, base := range plaintextBaseDirs() {
+		if info, statErr := os.Stat(base); statErr != nil || !info.IsDir() {
+			continue
+		}
+		// os.MkdirTemp creates the directory with mode 0700
+		dir, err = os.MkdirTemp(base, fmt.Sprintf("%s%d-*", plaintextDirPrefix, os.Getpid()))
+		if err == nil {
+			break
+		}
+	}
+	if dir == "" {
+		if err == nil {
+			err = fmt.Errorf("no usable temporary directory")
+		}
+		return nil, fmt.Errorf("unable to create temporary directory for editing: %w", err)
+	}
+
+	p := &plaintextFile{Path: filepath.Join(dir, plaintextFileName), dir: dir}
+	f, err := os.OpenFile(p.Path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		p.Remove()
+		return nil, fmt.Errorf("unable to create temporary file for editing: %w", err)
+	}
+	_, err = f.WriteString(content)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		p.Remove()
+		return nil, fmt.Errorf("unable to write temporary file for editing: %w", err)
+	}
+	return p, nil
+}
+
+// Remove overwrites every file in the directory, including swap and backup files the editor left there, and then
+// deletes the directory. It is safe to call more than once, and from a signal handler.
+func (p *plaintextFile) Remove() {
+	p.once.Do(func() {
+		entries, _ := os.ReadDir(p.dir)
+		for _, e := range entries {
+			if e.Type().IsRegular() {
+				scrubFile(filepath.Join(p.dir, e.Name()))
+			}
+		}
+		_ = os.RemoveAll(p.dir)
+	})
+}
+
+// scrubFile overwrites the content of a file with zeros. This is best effort: copy-on-write and journaling file
+// systems may keep the old blocks, which is why a memory-backed directory is preferred in the first place.
+func scrubFile(path string) {
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return
+	}
+	_, _ = f.Write(make([]byte, info.Size()))
+	_ = f.Sync()
+}
+
+// merge merges the edited file with the changes made elsewhere since base was read, using the private directory for
+// the other inputs of the merge.
+func (p *plaintextFile) merge(base string, theirs string, theirsLabel string) (string, int, error) {
+	basePath := filepath.Join(p.dir, "base.yml")
+	theirsPath := filepath.Join(p.dir, "theirs.yml")
+	defer scrubFile(basePath)
+	defer scrubFile(theirsPath)
+
+	err := os.WriteFile(basePath, []byte(base), 0o600)
+	if err != nil {
+		return "", 0, err
+	}
+	err = os.WriteFile(theirsPath, []byte(theirs), 0o600)
+	if err != nil {
+		return "", 0, err
+	}
+	return gitMergeFile(p.Path, basePath, theirsPath, [3]string{"your changes", "before editing", theirsLabel})
+}
+
+// removeOnSignal removes the plaintext file and exits when the process is interrupted or terminated. An interrupt
+// while editing is ignored, since terminals send it to the editor too and the editor decides what it means.
+// The returned function stops the handling.
+func (p *plaintextFile) removeOnSignal(editing *atomic.Bool) func() {
+	signals := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+	go func() {
+		for {
+			select {
+			case s := <-signals:
+				if s == os.Interrupt && editing.Load() {
+					continue
+				}
+				p.Remove()
+				status := 1
+				if n, ok := s.(syscall.Signal); ok {
+					status = 128 + int(n)
+				}
+				os.Exit(status)
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		signal.Stop(signals)
+		close(done)
+	}
+}
+
+// warnLeftoverPlaintext warns about plaintext directories whose edit session is no longer running.
+func warnLeftoverPlaintext() {
+	for _, base := range plaintextBaseDirs() {
+		entries, err := os.ReadDir(base)
+		if err != nil {
+			continue
+		}
+		for _, e := range entries {
+			if !e.IsDir() || !strings.HasPrefix(e.Name(), plaintextDirPrefix) {
+				continue
+			}
+			pid, _, _ := strings.Cut(strings.TrimPrefix(e.Name(), plaintextDirPrefix), "-")
+			n, err := strconv.Atoi(pid)
+			if err != nil || n == os.Getpid() || processRunning(n) {
+				continue
+			}
+			_, _ = fmt.Fprintf(os.Stderr, leftoverWarnTemplate, filepath.Join(base, e.Name()))
+		}
+	}
+}
@@ -0,0 +1,17 @@
+//go:build !unix
+
+package main
+
+import (
+	"os"
+)
+
+// processRunning reports whether a process exists. On Windows, looking up a process fails if it has exited.
+func processRunning(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	_ = p.Release()
+	return true
+}
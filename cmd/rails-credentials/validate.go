@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"github.com/jamesits/go-rails-credentials/pkg/credentials"
+	"os"
+)
+
+const (
+	schemaViolationsTemplate = "Schema violations in %s (%s):\n"
+	validTemplate            = "%s: OK\n"
+)
+
+type Validate struct {
+	All bool `name:"all" help:"Validate every credentials file in the project instead of only the current environment."`
+}
+
+func (cmd *Validate) Run(cli *Cli) error {
+	schema, err := cli.loadSchema()
+	if err != nil {
+		return err
+	}
+	if schema == nil {
+		return fmt.Errorf("schema file %s does not exist", cli.SchemaFile)
+	}
+
+	targets := []credentialsTarget{{
+		Environment:              cli.Environment,
+		MasterKeyFile:            cli.MasterKeyFile,
+		EncryptedCredentialsFile: cli.EncryptedCredentialsFile,
+	}}
+	if cmd.All {
+		targets, err = discoverTargets()
+		if err != nil {
+			return err
+		}
+	}
+
+	failed := 0
+	for _, t := range targets {
+		content, err := cli.decryptTarget(t)
+		if err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "%s: %s\n", t, err)
+			failed++
+			continue
+		}
+
+		tree, err := credentials.ParseContent(content)
+		if err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "%s: %s\n", t, err)
+			failed++
+			continue
+		}
+
+		violations := schema.ForEnvironment(t.Environment).Validate(tree)
+		if len(violations) > 0 {
+			printSchemaViolations(t.String(), cli.SchemaFile, violations)
+			failed++
+			continue
+		}
+		_, _ = fmt.Fprintf(os.Stdout, validTemplate, t)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d credentials files are not valid", failed, len(targets))
+	}
+	return nil
+}
+
+// loadSchema returns the parsed schema, or nil if the project does not have one.
+func (cli *Cli) loadSchema() (*credentials.Schema, error) {
+	content, err := os.ReadFile(cli.SchemaFile)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("unable to read schema file %s: %w", cli.SchemaFile, err)
+	}
+
+	schema, err := credentials.ParseSchema(content)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schema file %s: %w", cli.SchemaFile, err)
+	}
+	return schema, nil
+}
+
+func printSchemaViolations(name string, schemaFile string, violations []credentials.SchemaViolation) {
+	_, _ = fmt.Fprintf(os.Stderr, schemaViolationsTemplate, name, schemaFile)
+	for _, v := range violations {
+		_, _ = fmt.Fprintf(os.Stderr, "  - %s\n", v)
+	}
+}
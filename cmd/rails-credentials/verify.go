@@ -0,0 +1,279 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"github.com/jamesits/go-rails-credentials/pkg/credentials"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// verifyFailure is the kind of problem found by `verify`. The exit status is the bitwise OR of the kinds found, so CI
+// can tell them apart.
+type verifyFailure int
+
+const (
+	verifyMalformedEnvelope verifyFailure = 1 << (iota + 1)
+	verifyMissingKey
+	verifyDecryptFailed
+	verifyInvalidContent
+	verifySchemaViolation
+)
+
+func (f verifyFailure) String() string {
+	switch f {
+	case 0:
+		return ""
+	case verifyMalformedEnvelope:
+		return "malformed_envelope"
+	case verifyMissingKey:
+		return "missing_key"
+	case verifyDecryptFailed:
+		return "decrypt_failed"
+	case verifyInvalidContent:
+		return "invalid_content"
+	case verifySchemaViolation:
+		return "schema_violation"
+	default:
+		return fmt.Sprintf("failure_%d", int(f))
+	}
+}
+
+func (f verifyFailure) MarshalText() ([]byte, error) {
+	return []byte(f.String()), nil
+}
+
+type verifyResult struct {
+	File        string        `json:"file"`
+	Environment string        `json:"environment"`
+	KeySource   string        `json:"key_source,omitempty"`
+	Failure     verifyFailure `json:"failure,omitempty"`
+	Message     string        `json:"message,omitempty"`
+	Duration    time.Duration `json:"-"`
+}
+
+// verifyError exits with the combined failure kinds.
+type verifyError struct {
+	failures verifyFailure
+	failed   int
+	total    int
+}
+
+func (e verifyError) Error() string {
+	return fmt.Sprintf("%d of %d credentials files failed verification", e.failed, e.total)
+}
+
+func (e verifyError) ExitCode() int {
+	return int(e.failures)
+}
+
+type Verify struct {
+	Format string `name:"format" enum:"text,json,junit" default:"text" help:"Report format, one of: ${enum}."`
+	Output string `name:"output" short:"o" placeholder:"PATH" help:"Write the report to this file instead of stdout."`
+}
+
+func (cmd *Verify) Run(cli *Cli) error {
+	targets, err := discoverTargets()
+	if err != nil {
+		return err
+	}
+	if len(targets) == 0 {
+		return fmt.Errorf("no credentials files found")
+	}
+
+	schema, err := cli.loadSchema()
+	if err != nil {
+		return err
+	}
+
+	results := make([]verifyResult, len(targets))
+	wg := sync.WaitGroup{}
+	for i, t := range targets {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			start := time.Now()
+			results[i] = cli.verify(t, schema)
+			results[i].Duration = time.Since(start)
+		}()
+	}
+	wg.Wait()
+
+	out := io.Writer(os.Stdout)
+	if cmd.Output != "" {
+		f, err := os.Create(cmd.Output)
+		if err != nil {
+			return fmt.Errorf("unable to create report file: %w", err)
+		}
+		defer f.Close()
+		out = f
+	}
+
+	switch cmd.Format {
+	case "json":
+		err = writeVerifyJSON(out, results)
+	case "junit":
+		err = writeVerifyJUnit(out, results)
+	default:
+		err = writeVerifyText(out, results)
+	}
+	if err != nil {
+		return fmt.Errorf("unable to write report: %w", err)
+	}
+
+	e := verifyError{total: len(results)}
+	for _, r := range results {
+		if r.Failure != 0 {
+			e.failures |= r.Failure
+			e.failed++
+		}
+	}
+	if e.failed > 0 {
+		return e
+	}
+	return nil
+}
+
+// verify runs every check on one target, stopping at the first failure.
+func (cli *Cli) verify(t credentialsTarget, schema *credentials.Schema) (r verifyResult) {
+	r = verifyResult{File: t.EncryptedCredentialsFile, Environment: t.Environment}
+	fail := func(f verifyFailure, err error) verifyResult {
+		r.Failure = f
+		r.Message = err.Error()
+		return r
+	}
+
+	e, err := os.ReadFile(t.EncryptedCredentialsFile)
+	if err != nil {
+		return fail(verifyMalformedEnvelope, err)
+	}
+	_, err = credentials.ParseEnvelope(string(e))
+	if err != nil {
+		return fail(verifyMalformedEnvelope, err)
+	}
+
+	masterKey, source, err := cli.masterKey(t)
+	if err != nil {
+		return fail(verifyMissingKey, err)
+	}
+	if masterKey == "" {
+		variable := "RAILS_MASTER_KEY"
+		if t.Environment != "" {
+			variable = environmentKeyVariable(t.Environment)
+		}
+		return fail(verifyMissingKey, fmt.Errorf("set %s or create %s", variable, t.MasterKeyFile))
+	}
+	r.KeySource = source
+	err = credentials.ValidateMasterKey(masterKey)
+	if err != nil {
+		return fail(verifyMissingKey, err)
+	}
+
+	rawObject, err := credentials.Decrypt(masterKey, string(e))
+	if err != nil {
+		return fail(verifyDecryptFailed, err)
+	}
+	rawString, err := credentials.UnmarshalSingleString(rawObject)
+	if err != nil {
+		return fail(verifyInvalidContent, err)
+	}
+	tree, err := credentials.ParseContent(rawString)
+	if err != nil {
+		return fail(verifyInvalidContent, err)
+	}
+
+	if schema != nil {
+		violations := schema.ForEnvironment(t.Environment).Validate(tree)
+		if len(violations) > 0 {
+			return fail(verifySchemaViolation, fmt.Errorf("%d schema violations, first: %s", len(violations), violations[0]))
+		}
+	}
+	return r
+}
+
+func writeVerifyText(w io.Writer, results []verifyResult) error {
+	for _, r := range results {
+		var err error
+		if r.Failure == 0 {
+			_, err = fmt.Fprintf(w, "ok    %s (key from %s)\n", r.File, r.KeySource)
+		} else {
+			_, err = fmt.Fprintf(w, "FAIL  %s: %s: %s\n", r.File, r.Failure, r.Message)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeVerifyJSON(w io.Writer, results []verifyResult) error {
+	failed := 0
+	for _, r := range results {
+		if r.Failure != 0 {
+			failed++
+		}
+	}
+
+	e := json.NewEncoder(w)
+	e.SetIndent("", "  ")
+	return e.Encode(struct {
+		Results []verifyResult `json:"results"`
+		Failed  int            `json:"failed"`
+	}{results, failed})
+}
+
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Time     string          `xml:"time,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	ClassName string        `xml:"classname,attr"`
+	Name      string        `xml:"name,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+}
+
+type junitFailure struct {
+	Type    string `xml:"type,attr"`
+	Message string `xml:"message,attr"`
+}
+
+func writeVerifyJUnit(w io.Writer, results []verifyResult) error {
+	suite := junitTestSuite{Name: "rails-credentials verify", Tests: len(results)}
+	var total time.Duration
+	for _, r := range results {
+		c := junitTestCase{ClassName: "credentials", Name: r.File, Time: fmt.Sprintf("%.3f", r.Duration.Seconds())}
+		if r.Failure != 0 {
+			c.Failure = &junitFailure{Type: r.Failure.String(), Message: r.Message}
+			suite.Failures++
+		}
+		suite.Cases = append(suite.Cases, c)
+		total += r.Duration
+	}
+	suite.Time = fmt.Sprintf("%.3f", total.Seconds())
+
+	_, err := io.WriteString(w, xml.Header)
+	if err != nil {
+		return err
+	}
+	e := xml.NewEncoder(w)
+	e.Indent("", "  ")
+	err = e.Encode(junitTestSuites{Suites: []junitTestSuite{suite}})
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, "\n")
+	return err
+}
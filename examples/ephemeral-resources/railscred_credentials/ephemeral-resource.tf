@@ -0,0 +1,9 @@
+ephemeral "railscred_credentials" "example" {
+  master_key        = file("${path.module}/config/master.key")
+  encrypted_content = file("${path.module}/config/credentials.yml.enc")
+}
+
+provider "aws" {
+  access_key = ephemeral.railscred_credentials.example.values.aws.access_key_id
+  secret_key = ephemeral.railscred_credentials.example.values.aws.secret_access_key
+}
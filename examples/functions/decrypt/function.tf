@@ -0,0 +1,4 @@
+output "credentials" {
+  value     = provider::railscred::decrypt(file("${path.module}/config/master.key"), file("${path.module}/config/credentials.yml.enc"))
+  sensitive = true
+}
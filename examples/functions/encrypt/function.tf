@@ -0,0 +1,4 @@
+resource "local_sensitive_file" "credentials" {
+  filename = "${path.module}/config/credentials.yml.enc"
+  content  = provider::railscred::encrypt(file("${path.module}/config/master.key"), file("${path.module}/credentials.yml"))
+}
@@ -0,0 +1,4 @@
+provider "aws" {
+  access_key = provider::railscred::fetch(file("${path.module}/config/master.key"), file("${path.module}/config/credentials.yml.enc"), "aws.access_key_id")
+  secret_key = provider::railscred::fetch(file("${path.module}/config/master.key"), file("${path.module}/config/credentials.yml.enc"), "aws.secret_access_key")
+}
@@ -0,0 +1,10 @@
+locals {
+  credentials = provider::railscred::yamldecode_credentials(
+    provider::railscred::decrypt(file("${path.module}/config/master.key"), file("${path.module}/config/credentials.yml.enc"))
+  )
+}
+
+output "smtp_user_name" {
+  value     = local.credentials.smtp.user_name
+  sensitive = true
+}
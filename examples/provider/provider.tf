@@ -1 +1,4 @@
-provider "railscred" {}
+provider "railscred" {
+  # the Rails project, with config/master.key and config/credentials/*.yml.enc
+  base_dir = "${path.root}/.."
+}
@@ -0,0 +1,12 @@
+resource "railscred_master_key" "example" {}
+
+resource "railscred_active_record_encryption_keys" "example" {
+  keepers = {
+    database = "production"
+  }
+}
+
+resource "railscred_credentials" "example" {
+  master_key = railscred_master_key.example.master_key
+  content    = railscred_active_record_encryption_keys.example.content
+}
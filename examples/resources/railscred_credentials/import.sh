@@ -0,0 +1,2 @@
+# The import ID is the content of the encrypted credentials file.
+terraform import railscred_credentials.example "$(cat config/credentials.yml.enc)"
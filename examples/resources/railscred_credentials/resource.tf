@@ -0,0 +1,13 @@
+resource "railscred_master_key" "example" {}
+
+resource "railscred_credentials" "example" {
+  master_key = railscred_master_key.example.master_key
+  content    = <<-EOT
+# aws:
+#   access_key_id: 123
+#   secret_access_key: 345
+
+# Used as the base secret for all MessageVerifiers in Rails, including the one protecting cookies.
+secret_key_base:
+EOT
+}
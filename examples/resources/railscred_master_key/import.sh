@@ -0,0 +1,2 @@
+# The import ID is the content of an existing master key file.
+terraform import railscred_master_key.example "$(cat config/master.key)"
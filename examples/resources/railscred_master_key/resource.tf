@@ -1 +1,11 @@
-resource "railscred_master_key" "example" {}
+resource "railscred_master_key" "example" {
+  # generate a new key every 90 days, or when the environment is rebuilt
+  rotation_days = 90
+  keepers = {
+    environment = "production"
+  }
+}
+
+output "master_key_fingerprint" {
+  value = railscred_master_key.example.fingerprint
+}
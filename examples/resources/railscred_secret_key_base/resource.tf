@@ -0,0 +1,10 @@
+resource "railscred_master_key" "example" {}
+
+resource "railscred_secret_key_base" "example" {}
+
+resource "railscred_credentials" "example" {
+  master_key = railscred_master_key.example.master_key
+  content    = yamlencode({
+    secret_key_base = railscred_secret_key_base.example.secret_key_base
+  })
+}
@@ -0,0 +1,127 @@
+package provider
+
+import (
+	"errors"
+	"fmt"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/function"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/jamesits/go-rails-credentials/pkg/credentials"
+	"os"
+	"path/filepath"
+)
+
+// decryptContent decrypts the content of an encrypted credentials file into the plaintext YAML.
+func decryptContent(masterKey string, encryptedContent string) (string, error) {
+	rawObject, err := credentials.Decrypt(credentials.SanitizeMasterKey(masterKey), encryptedContent)
+	if err != nil {
+		return "", fmt.Errorf("decrypt failed: %w", err)
+	}
+	rawString, err := credentials.UnmarshalSingleString(rawObject)
+	if err != nil {
+		return "", fmt.Errorf("unmarshal failed: %w", err)
+	}
+	return rawString, nil
+}
+
+// encryptContent encrypts the plaintext YAML into the content of an encrypted credentials file.
+func encryptContent(masterKey string, content string) (string, error) {
+	rawObject, err := credentials.MarshalSingleString(content)
+	if err != nil {
+		return "", fmt.Errorf("marshal failed: %w", err)
+	}
+	encrypted, err := credentials.Encrypt(credentials.SanitizeMasterKey(masterKey), rawObject)
+	if err != nil {
+		return "", fmt.Errorf("encrypt failed: %w", err)
+	}
+	return encrypted, nil
+}
+
+// encryptContentDeterministic is encryptContent with credentials.EncryptDeterministic, for functions, which must
+// return the same result for the same arguments.
+func encryptContentDeterministic(masterKey string, content string) (string, error) {
+	rawObject, err := credentials.MarshalSingleString(content)
+	if err != nil {
+		return "", fmt.Errorf("marshal failed: %w", err)
+	}
+	encrypted, err := credentials.EncryptDeterministic(credentials.SanitizeMasterKey(masterKey), rawObject)
+	if err != nil {
+		return "", fmt.Errorf("encrypt failed: %w", err)
+	}
+	return encrypted, nil
+}
+
+// decryptArguments decrypts the (master_key, encrypted_content) arguments of a function, and points the error at the
+// argument that is wrong where that can be told.
+func decryptArguments(masterKey string, encryptedContent string) (string, *function.FuncError) {
+	err := credentials.ValidateMasterKey(credentials.SanitizeMasterKey(masterKey))
+	if err != nil {
+		return "", function.NewArgumentFuncError(0, err.Error())
+	}
+	_, err = credentials.ParseEnvelope(encryptedContent)
+	if err != nil {
+		return "", function.NewArgumentFuncError(1, "invalid encrypted credentials: "+err.Error())
+	}
+	rawString, err := decryptContent(masterKey, encryptedContent)
+	if err != nil {
+		return "", function.NewFuncError("unable to decrypt, the master key is wrong or the content is corrupted: " + errors.Unwrap(err).Error())
+	}
+	return rawString, nil
+}
+
+// resolveCredentials fills in the master key and the encrypted content a data source does not set, like the CLI does:
+// the encrypted content is read from the credentials file of the environment, and the master key comes from the
+// provider configuration or the key file of the environment.
+//
+// A data source that sets its own environment uses the key file of that environment first, since the provider master
+// key most likely belongs to another one. The environment defaults to the one in the provider configuration.
+func (c *providerConfig) resolveCredentials(environment types.String, masterKey types.String, encryptedContent types.String) (string, string, diag.Diagnostics) {
+	var diags diag.Diagnostics
+
+	config := providerConfig{BaseDir: "."}
+	if c != nil {
+		config = *c
+	}
+	env := config.Environment
+	if !environment.IsNull() {
+		env = environment.ValueString()
+	}
+	masterKeyFile, encryptedCredentialsFile := credentials.DefaultPaths(env)
+	masterKeyFile = filepath.Join(config.BaseDir, masterKeyFile)
+	encryptedCredentialsFile = filepath.Join(config.BaseDir, encryptedCredentialsFile)
+
+	e := encryptedContent.ValueString()
+	if encryptedContent.IsNull() {
+		b, err := os.ReadFile(encryptedCredentialsFile)
+		if err != nil {
+			diags.AddAttributeError(path.Root("encrypted_content"), "Credentials file not found", fmt.Sprintf("encrypted_content is not set, and the credentials file can not be read: %s", err))
+			return "", "", diags
+		}
+		e = string(b)
+	}
+
+	if !masterKey.IsNull() {
+		return masterKey.ValueString(), e, diags
+	}
+	readKeyFile := func() (string, bool) {
+		b, err := os.ReadFile(masterKeyFile)
+		if err != nil {
+			return "", false
+		}
+		return credentials.SanitizeMasterKey(string(b)), true
+	}
+	if !environment.IsNull() && environment.ValueString() != config.Environment {
+		if k, ok := readKeyFile(); ok {
+			return k, e, diags
+		}
+	}
+	if config.MasterKey != "" {
+		return config.MasterKey, e, diags
+	}
+	if k, ok := readKeyFile(); ok {
+		return k, e, diags
+	}
+	diags.AddAttributeError(path.Root("master_key"), "Master key not found", fmt.Sprintf("master_key is not set here or in the provider configuration, RAILS_MASTER_KEY is not set, and %s can not be read.", masterKeyFile))
+	return "", "", diags
+}
@@ -0,0 +1,55 @@
+package provider
+
+import (
+	"context"
+	"github.com/hashicorp/terraform-plugin-framework/function"
+)
+
+// Ensure provider defined types fully satisfy framework interfaces.
+var _ function.Function = &DecryptFunction{}
+
+func NewDecryptFunction() function.Function {
+	return &DecryptFunction{}
+}
+
+// DecryptFunction decrypts a credentials file into the plaintext YAML.
+type DecryptFunction struct{}
+
+func (f *DecryptFunction) Metadata(ctx context.Context, req function.MetadataRequest, resp *function.MetadataResponse) {
+	resp.Name = "decrypt"
+}
+
+func (f *DecryptFunction) Definition(ctx context.Context, req function.DefinitionRequest, resp *function.DefinitionResponse) {
+	resp.Definition = function.Definition{
+		Summary:             "Decrypts a Rails credentials file",
+		MarkdownDescription: "Decrypts the content of a Rails credentials file into the plaintext YAML.",
+		Parameters: []function.Parameter{
+			function.StringParameter{
+				Name:                "master_key",
+				MarkdownDescription: "The master key",
+			},
+			function.StringParameter{
+				Name:                "encrypted_content",
+				MarkdownDescription: "The credentials file content",
+			},
+		},
+		Return: function.StringReturn{},
+	}
+}
+
+func (f *DecryptFunction) Run(ctx context.Context, req function.RunRequest, resp *function.RunResponse) {
+	var masterKey, encryptedContent string
+
+	resp.Error = req.Arguments.Get(ctx, &masterKey, &encryptedContent)
+	if resp.Error != nil {
+		return
+	}
+
+	rawString, funcErr := decryptArguments(masterKey, encryptedContent)
+	if funcErr != nil {
+		resp.Error = funcErr
+		return
+	}
+
+	resp.Error = resp.Result.Set(ctx, rawString)
+}
@@ -0,0 +1,67 @@
+package provider
+
+import (
+	"context"
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/function"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestFunctionErrors(t *testing.T) {
+	encrypted, err := encryptContent(testMasterKey, "aws:\n  access_key_id: AKIA\n")
+	assert.NoError(t, err)
+
+	for _, tc := range []struct {
+		name     string
+		function function.Function
+		result   attr.Value
+		args     []attr.Value
+		// the argument the error points at, or -1 for a function error
+		argument int64
+	}{
+		{
+			name:     "bad key",
+			function: NewDecryptFunction(),
+			result:   types.StringUnknown(),
+			args:     []attr.Value{types.StringValue("not a key"), types.StringValue(encrypted)},
+			argument: 0,
+		},
+		{
+			name:     "malformed envelope",
+			function: NewDecryptFunction(),
+			result:   types.StringUnknown(),
+			args:     []attr.Value{types.StringValue(testMasterKey), types.StringValue("not encrypted")},
+			argument: 1,
+		},
+		{
+			name:     "wrong key",
+			function: NewDecryptFunction(),
+			result:   types.StringUnknown(),
+			args:     []attr.Value{types.StringValue(testOtherMasterKey), types.StringValue(encrypted)},
+			argument: -1,
+		},
+		{
+			name:     "fetch miss",
+			function: NewFetchFunction(),
+			result:   types.DynamicUnknown(),
+			args:     []attr.Value{types.StringValue(testMasterKey), types.StringValue(encrypted), types.StringValue("aws.secret_access_key")},
+			argument: 2,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			resp := function.RunResponse{Result: function.NewResultData(tc.result)}
+			tc.function.Run(context.Background(), function.RunRequest{Arguments: function.NewArgumentsData(tc.args)}, &resp)
+
+			if !assert.NotNil(t, resp.Error) {
+				return
+			}
+			if tc.argument < 0 {
+				assert.Nil(t, resp.Error.FunctionArgument, resp.Error.Text)
+			} else if assert.NotNil(t, resp.Error.FunctionArgument, resp.Error.Text) {
+				assert.Equal(t, tc.argument, *resp.Error.FunctionArgument, resp.Error.Text)
+			}
+		})
+	}
+}
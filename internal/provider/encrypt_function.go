@@ -0,0 +1,62 @@
+package provider
+
+import (
+	"context"
+	"github.com/hashicorp/terraform-plugin-framework/function"
+	"github.com/jamesits/go-rails-credentials/pkg/credentials"
+)
+
+// Ensure provider defined types fully satisfy framework interfaces.
+var _ function.Function = &EncryptFunction{}
+
+func NewEncryptFunction() function.Function {
+	return &EncryptFunction{}
+}
+
+// EncryptFunction encrypts plaintext YAML into a credentials file. Functions must return the same result for the
+// same arguments, so the IV is derived from the key and the content instead of being random.
+type EncryptFunction struct{}
+
+func (f *EncryptFunction) Metadata(ctx context.Context, req function.MetadataRequest, resp *function.MetadataResponse) {
+	resp.Name = "encrypt"
+}
+
+func (f *EncryptFunction) Definition(ctx context.Context, req function.DefinitionRequest, resp *function.DefinitionResponse) {
+	resp.Definition = function.Definition{
+		Summary:             "Encrypts a Rails credentials file",
+		MarkdownDescription: "Encrypts plaintext YAML into the content of a Rails credentials file. The result only changes when the key or the content does, but reveals whether two files encrypted with the same key have the same content.",
+		Parameters: []function.Parameter{
+			function.StringParameter{
+				Name:                "master_key",
+				MarkdownDescription: "The master key",
+			},
+			function.StringParameter{
+				Name:                "content",
+				MarkdownDescription: "Raw credentials in YAML format",
+			},
+		},
+		Return: function.StringReturn{},
+	}
+}
+
+func (f *EncryptFunction) Run(ctx context.Context, req function.RunRequest, resp *function.RunResponse) {
+	var masterKey, content string
+
+	resp.Error = req.Arguments.Get(ctx, &masterKey, &content)
+	if resp.Error != nil {
+		return
+	}
+
+	err := credentials.ValidateMasterKey(credentials.SanitizeMasterKey(masterKey))
+	if err != nil {
+		resp.Error = function.NewArgumentFuncError(0, err.Error())
+		return
+	}
+	encryptedString, err := encryptContentDeterministic(masterKey, content)
+	if err != nil {
+		resp.Error = function.NewFuncError(err.Error())
+		return
+	}
+
+	resp.Error = resp.Result.Set(ctx, encryptedString)
+}
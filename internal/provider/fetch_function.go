@@ -0,0 +1,78 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"github.com/hashicorp/terraform-plugin-framework/function"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/jamesits/go-rails-credentials/pkg/credentials"
+)
+
+// Ensure provider defined types fully satisfy framework interfaces.
+var _ function.Function = &FetchFunction{}
+
+func NewFetchFunction() function.Function {
+	return &FetchFunction{}
+}
+
+// FetchFunction decrypts a credentials file and returns the value at a key path, like
+// Rails.application.credentials.dig.
+type FetchFunction struct{}
+
+func (f *FetchFunction) Metadata(ctx context.Context, req function.MetadataRequest, resp *function.MetadataResponse) {
+	resp.Name = "fetch"
+}
+
+func (f *FetchFunction) Definition(ctx context.Context, req function.DefinitionRequest, resp *function.DefinitionResponse) {
+	resp.Definition = function.Definition{
+		Summary:             "Reads one value from a Rails credentials file",
+		MarkdownDescription: "Decrypts a Rails credentials file and returns the value at a key path, e.g. `aws.access_key_id`. Scalars keep their YAML type, and a path to a mapping or a sequence returns an object or a tuple.",
+		Parameters: []function.Parameter{
+			function.StringParameter{
+				Name:                "master_key",
+				MarkdownDescription: "The master key",
+			},
+			function.StringParameter{
+				Name:                "encrypted_content",
+				MarkdownDescription: "The credentials file content",
+			},
+			function.StringParameter{
+				Name:                "path",
+				MarkdownDescription: "The dotted key path, with sequence items addressed by their index, e.g. `hosts.0`",
+			},
+		},
+		Return: function.DynamicReturn{},
+	}
+}
+
+func (f *FetchFunction) Run(ctx context.Context, req function.RunRequest, resp *function.RunResponse) {
+	var masterKey, encryptedContent, path string
+
+	resp.Error = req.Arguments.Get(ctx, &masterKey, &encryptedContent, &path)
+	if resp.Error != nil {
+		return
+	}
+
+	rawString, funcErr := decryptArguments(masterKey, encryptedContent)
+	if funcErr != nil {
+		resp.Error = funcErr
+		return
+	}
+	tree, err := credentials.ParseContent(rawString)
+	if err != nil {
+		resp.Error = function.NewFuncError("the decrypted credentials are not a valid YAML mapping: " + err.Error())
+		return
+	}
+	node, ok := credentials.Lookup(tree, credentials.ParsePath(path))
+	if !ok {
+		resp.Error = function.NewArgumentFuncError(2, fmt.Sprintf("no credentials found under %s", path))
+		return
+	}
+
+	v, diags := treeValue(node)
+	if diags.HasError() {
+		resp.Error = function.FuncErrorFromDiags(ctx, diags)
+		return
+	}
+	resp.Error = resp.Result.Set(ctx, types.DynamicValue(v))
+}
@@ -8,6 +8,10 @@ import (
 	"github.com/hashicorp/terraform-plugin-framework/provider"
 	"github.com/hashicorp/terraform-plugin-framework/provider/schema"
 	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/jamesits/go-rails-credentials/pkg/credentials"
+	"os"
 )
 
 // Ensure RailsCredentialProvider satisfies various provider interfaces.
@@ -24,7 +28,19 @@ type RailsCredentialProvider struct {
 }
 
 // RailsCredentialProviderModel describes the provider data model.
-type RailsCredentialProviderModel struct{}
+type RailsCredentialProviderModel struct {
+	MasterKey   types.String `tfsdk:"master_key"`
+	BaseDir     types.String `tfsdk:"base_dir"`
+	Environment types.String `tfsdk:"environment"`
+}
+
+// providerConfig is the resolved provider configuration, handed to the data sources and resources.
+type providerConfig struct {
+	// MasterKey is empty if neither the configuration nor RAILS_MASTER_KEY sets it.
+	MasterKey   string
+	BaseDir     string
+	Environment string
+}
 
 func (p *RailsCredentialProvider) Metadata(ctx context.Context, req provider.MetadataRequest, resp *provider.MetadataResponse) {
 	resp.TypeName = "railscred"
@@ -34,6 +50,25 @@ func (p *RailsCredentialProvider) Metadata(ctx context.Context, req provider.Met
 func (p *RailsCredentialProvider) Schema(ctx context.Context, req provider.SchemaRequest, resp *provider.SchemaResponse) {
 	resp.Schema = schema.Schema{
 		MarkdownDescription: "Manage Rails credentials files.",
+
+		Attributes: map[string]schema.Attribute{
+			"master_key": schema.StringAttribute{
+				MarkdownDescription: "The master key used when a data source does not set one. Defaults to the `RAILS_MASTER_KEY` environment variable, and then to the key file of the environment",
+				Optional:            true,
+				Sensitive:           true,
+				Validators: []validator.String{
+					masterKeyValidator{},
+				},
+			},
+			"base_dir": schema.StringAttribute{
+				MarkdownDescription: "Root directory of the Rails project, relative to the working directory. Defaults to the working directory",
+				Optional:            true,
+			},
+			"environment": schema.StringAttribute{
+				MarkdownDescription: "The Rails environment whose credentials are read when a data source does not set one, e.g. `production` for `config/credentials/production.yml.enc`. Defaults to the `RAILS_ENV` environment variable, and then to the global `config/credentials.yml.enc`",
+				Optional:            true,
+			},
+		},
 	}
 }
 
@@ -45,16 +80,42 @@ func (p *RailsCredentialProvider) Configure(ctx context.Context, req provider.Co
 	if resp.Diagnostics.HasError() {
 		return
 	}
+
+	// the same defaults as the CLI
+	config := &providerConfig{
+		MasterKey:   os.Getenv("RAILS_MASTER_KEY"),
+		BaseDir:     ".",
+		Environment: os.Getenv("RAILS_ENV"),
+	}
+	if !data.MasterKey.IsNull() {
+		config.MasterKey = data.MasterKey.ValueString()
+	}
+	if !data.BaseDir.IsNull() {
+		config.BaseDir = data.BaseDir.ValueString()
+	}
+	if !data.Environment.IsNull() {
+		config.Environment = data.Environment.ValueString()
+	}
+	config.MasterKey = credentials.SanitizeMasterKey(config.MasterKey)
+
+	resp.DataSourceData = config
+	resp.ResourceData = config
+	resp.EphemeralResourceData = config
 }
 
 func (p *RailsCredentialProvider) Resources(ctx context.Context) []func() resource.Resource {
 	return []func() resource.Resource{
 		NewRailsMasterKeyResource,
+		NewRailsCredentialsResource,
+		NewRailsSecretKeyBaseResource,
+		NewRailsActiveRecordEncryptionKeysResource,
 	}
 }
 
 func (p *RailsCredentialProvider) EphemeralResources(ctx context.Context) []func() ephemeral.EphemeralResource {
-	return []func() ephemeral.EphemeralResource{}
+	return []func() ephemeral.EphemeralResource{
+		NewRailsCredentialsEphemeralResource,
+	}
 }
 
 func (p *RailsCredentialProvider) DataSources(ctx context.Context) []func() datasource.DataSource {
@@ -65,7 +126,12 @@ func (p *RailsCredentialProvider) DataSources(ctx context.Context) []func() data
 }
 
 func (p *RailsCredentialProvider) Functions(ctx context.Context) []func() function.Function {
-	return []func() function.Function{}
+	return []func() function.Function{
+		NewDecryptFunction,
+		NewEncryptFunction,
+		NewFetchFunction,
+		NewYAMLDecodeCredentialsFunction,
+	}
 }
 
 func New(version string) func() provider.Provider {
@@ -0,0 +1,123 @@
+package provider
+
+import (
+	"context"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/mapplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/jamesits/go-rails-credentials/pkg/credentials"
+)
+
+// Ensure provider defined types fully satisfy framework interfaces.
+var _ resource.Resource = &RailsActiveRecordEncryptionKeysResource{}
+
+func NewRailsActiveRecordEncryptionKeysResource() resource.Resource {
+	return &RailsActiveRecordEncryptionKeysResource{}
+}
+
+// RailsActiveRecordEncryptionKeysResource generates the Active Record encryption keys, which only exist in the state.
+type RailsActiveRecordEncryptionKeysResource struct{}
+
+// RailsActiveRecordEncryptionKeysResourceModel describes the resource data model.
+type RailsActiveRecordEncryptionKeysResourceModel struct {
+	Keepers           types.Map    `tfsdk:"keepers"`
+	PrimaryKey        types.String `tfsdk:"primary_key"`
+	DeterministicKey  types.String `tfsdk:"deterministic_key"`
+	KeyDerivationSalt types.String `tfsdk:"key_derivation_salt"`
+	Content           types.String `tfsdk:"content"`
+}
+
+func (r *RailsActiveRecordEncryptionKeysResource) Metadata(ctx context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_active_record_encryption_keys"
+}
+
+func (r *RailsActiveRecordEncryptionKeysResource) Schema(ctx context.Context, req resource.SchemaRequest, resp *resource.SchemaResponse) {
+	computed := func(description string) schema.StringAttribute {
+		return schema.StringAttribute{
+			MarkdownDescription: description,
+
+			Computed:  true,
+			Sensitive: true,
+
+			PlanModifiers: []planmodifier.String{
+				stringplanmodifier.UseStateForUnknown(),
+			},
+		}
+	}
+
+	resp.Schema = schema.Schema{
+		// This description is used by the documentation generator and the language server.
+		MarkdownDescription: "Random-generated Active Record encryption keys, like `bin/rails db:encryption:init`",
+
+		Attributes: map[string]schema.Attribute{
+			"keepers": schema.MapAttribute{
+				MarkdownDescription: "Arbitrary values that generate new keys when they change, like the `keepers` of the `random` provider. Records encrypted with the old keys can not be read with the new ones",
+				ElementType:         types.StringType,
+				Optional:            true,
+
+				PlanModifiers: []planmodifier.Map{
+					mapplanmodifier.RequiresReplace(),
+				},
+			},
+			"primary_key":         computed("`active_record_encryption.primary_key`, 32 alphanumeric characters"),
+			"deterministic_key":   computed("`active_record_encryption.deterministic_key`, 32 alphanumeric characters"),
+			"key_derivation_salt": computed("`active_record_encryption.key_derivation_salt`, 32 alphanumeric characters"),
+			"content":             computed("The keys as the `active_record_encryption` section of the credentials in YAML format, to append to the `content` of `railscred_credentials`"),
+		},
+	}
+}
+
+func (r *RailsActiveRecordEncryptionKeysResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	var data RailsActiveRecordEncryptionKeysResourceModel
+
+	// Read Terraform plan data into the model
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &data)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	keys, err := credentials.RandomActiveRecordEncryptionKeys()
+	if err != nil {
+		resp.Diagnostics.AddError("unable to generate Active Record encryption keys", err.Error())
+		return
+	}
+	content, err := credentials.EncodeContent(map[string]any{"active_record_encryption": keys})
+	if err != nil {
+		resp.Diagnostics.AddError("unable to generate Active Record encryption keys", err.Error())
+		return
+	}
+	data.PrimaryKey = types.StringValue(keys.PrimaryKey)
+	data.DeterministicKey = types.StringValue(keys.DeterministicKey)
+	data.KeyDerivationSalt = types.StringValue(keys.KeyDerivationSalt)
+	data.Content = types.StringValue(content)
+
+	// Save data into Terraform state
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+func (r *RailsActiveRecordEncryptionKeysResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	// the keys only exist in the state
+}
+
+func (r *RailsActiveRecordEncryptionKeysResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	// every attribute either requires replacement or is computed, so there is nothing to update
+	var data RailsActiveRecordEncryptionKeysResourceModel
+
+	// Read Terraform plan data into the model
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &data)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	// Save updated data into Terraform state
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+func (r *RailsActiveRecordEncryptionKeysResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	// the keys only exist in the state
+}
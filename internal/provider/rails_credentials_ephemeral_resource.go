@@ -0,0 +1,123 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"github.com/hashicorp/terraform-plugin-framework/ephemeral"
+	"github.com/hashicorp/terraform-plugin-framework/ephemeral/schema"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+// Ensure provider defined types fully satisfy framework interfaces.
+var _ ephemeral.EphemeralResource = &RailsCredentialsEphemeralResource{}
+var _ ephemeral.EphemeralResourceWithConfigure = &RailsCredentialsEphemeralResource{}
+
+func NewRailsCredentialsEphemeralResource() ephemeral.EphemeralResource {
+	return &RailsCredentialsEphemeralResource{}
+}
+
+// RailsCredentialsEphemeralResource decrypts credentials without storing them in the state or plan.
+type RailsCredentialsEphemeralResource struct {
+	config *providerConfig
+}
+
+// RailsCredentialsEphemeralResourceModel describes the ephemeral resource data model.
+type RailsCredentialsEphemeralResourceModel struct {
+	Environment      types.String  `tfsdk:"environment"`
+	MasterKey        types.String  `tfsdk:"master_key"`
+	EncryptedContent types.String  `tfsdk:"encrypted_content"`
+	DecryptedContent types.String  `tfsdk:"content"`
+	Values           types.Dynamic `tfsdk:"values"`
+}
+
+func (r *RailsCredentialsEphemeralResource) Metadata(ctx context.Context, req ephemeral.MetadataRequest, resp *ephemeral.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_credentials"
+}
+
+func (r *RailsCredentialsEphemeralResource) Schema(ctx context.Context, req ephemeral.SchemaRequest, resp *ephemeral.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		// This description is used by the documentation generator and the language server.
+		MarkdownDescription: "Decrypts a Rails credentials file during the run only, without storing the result in the state or plan.",
+
+		Attributes: map[string]schema.Attribute{
+			"environment": schema.StringAttribute{
+				MarkdownDescription: "The Rails environment whose credentials are read when `encrypted_content` is not set, and whose key file is used when `master_key` is not set. Defaults to the provider `environment`",
+				Optional:            true,
+			},
+			"master_key": schema.StringAttribute{
+				MarkdownDescription: "The master key. Defaults to the provider `master_key`, and then to the key file of the environment",
+				Optional:            true,
+				Sensitive:           true,
+				Validators: []validator.String{
+					masterKeyValidator{},
+				},
+			},
+			"encrypted_content": schema.StringAttribute{
+				MarkdownDescription: "The credentials file content. Defaults to the credentials file of the environment",
+				Optional:            true,
+				Validators: []validator.String{
+					encryptedContentValidator{},
+				},
+			},
+			"content": schema.StringAttribute{
+				MarkdownDescription: "Decrypted credentials in YAML format",
+				Computed:            true,
+				Sensitive:           true,
+			},
+			"values": schema.DynamicAttribute{
+				MarkdownDescription: "Decrypted credentials as an object, e.g. `values.aws.access_key_id`",
+				Computed:            true,
+				Sensitive:           true,
+			},
+		},
+	}
+}
+
+func (r *RailsCredentialsEphemeralResource) Configure(ctx context.Context, req ephemeral.ConfigureRequest, resp *ephemeral.ConfigureResponse) {
+	// Prevent panic if the provider has not been configured.
+	if req.ProviderData == nil {
+		return
+	}
+
+	config, ok := req.ProviderData.(*providerConfig)
+	if !ok {
+		resp.Diagnostics.AddError("Unexpected Ephemeral Resource Configure Type", fmt.Sprintf("Expected *providerConfig, got: %T. Please report this issue to the provider developers.", req.ProviderData))
+		return
+	}
+	r.config = config
+}
+
+func (r *RailsCredentialsEphemeralResource) Open(ctx context.Context, req ephemeral.OpenRequest, resp *ephemeral.OpenResponse) {
+	var data RailsCredentialsEphemeralResourceModel
+
+	// Read Terraform configuration data into the model
+	resp.Diagnostics.Append(req.Config.Get(ctx, &data)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	masterKey, encryptedContent, diags := r.config.resolveCredentials(data.Environment, data.MasterKey, data.EncryptedContent)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	rawString, err := decryptContent(masterKey, encryptedContent)
+	if err != nil {
+		resp.Diagnostics.AddError("Credentials decryption failed", err.Error())
+		return
+	}
+	data.DecryptedContent = types.StringValue(rawString)
+
+	values, diags := contentValues(rawString)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	data.Values = values
+
+	// Save data into the ephemeral result
+	resp.Diagnostics.Append(resp.Result.Set(ctx, &data)...)
+}
@@ -2,27 +2,38 @@ package provider
 
 import (
 	"context"
+	"fmt"
 	"github.com/hashicorp/terraform-plugin-framework/datasource"
 	"github.com/hashicorp/terraform-plugin-framework/datasource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
 	"github.com/hashicorp/terraform-plugin-framework/types"
 	"github.com/jamesits/go-rails-credentials/pkg/credentials"
 )
 
 // Ensure provider defined types fully satisfy framework interfaces.
 var _ datasource.DataSource = &RailsCredentialsFileDataSource{}
+var _ datasource.DataSourceWithConfigure = &RailsCredentialsFileDataSource{}
 
 func NewRailsCredentialsFileDataSource() datasource.DataSource {
 	return &RailsCredentialsFileDataSource{}
 }
 
-// RailsCredentialsFileDataSource defines the data source implementation.
-type RailsCredentialsFileDataSource struct{}
+// RailsCredentialsFileDataSource defines the data source implementation. Without the content or the key, it reads the
+// files of the Rails project configured in the provider.
+type RailsCredentialsFileDataSource struct {
+	config *providerConfig
+}
 
 // RailsCredentialsFileDataSourceModel describes the data source data model.
 type RailsCredentialsFileDataSourceModel struct {
-	MasterKey        types.String `tfsdk:"master_key"`
-	EncryptedContent types.String `tfsdk:"encrypted_content"`
-	DecryptedContent types.String `tfsdk:"content"`
+	Environment      types.String  `tfsdk:"environment"`
+	MasterKey        types.String  `tfsdk:"master_key"`
+	EncryptedContent types.String  `tfsdk:"encrypted_content"`
+	Paths            types.List    `tfsdk:"paths"`
+	DecryptedContent types.String  `tfsdk:"content"`
+	Values           types.Dynamic `tfsdk:"values"`
+	Flat             types.Map     `tfsdk:"flat"`
 }
 
 func (d *RailsCredentialsFileDataSource) Metadata(ctx context.Context, req datasource.MetadataRequest, resp *datasource.MetadataResponse) {
@@ -35,17 +46,43 @@ func (d *RailsCredentialsFileDataSource) Schema(ctx context.Context, req datasou
 		MarkdownDescription: "Reads and decrypts a Rails credentials file.",
 
 		Attributes: map[string]schema.Attribute{
+			"environment": schema.StringAttribute{
+				MarkdownDescription: "The Rails environment whose credentials are read when `encrypted_content` is not set, and whose key file is used when `master_key` is not set. Defaults to the provider `environment`",
+				Optional:            true,
+			},
 			"master_key": schema.StringAttribute{
-				MarkdownDescription: "The master key",
-				Required:            true,
+				MarkdownDescription: "The master key. Defaults to the provider `master_key`, and then to the key file of the environment",
+				Optional:            true,
 				Sensitive:           true,
+				Validators: []validator.String{
+					masterKeyValidator{},
+				},
 			},
 			"encrypted_content": schema.StringAttribute{
-				MarkdownDescription: "The credentials file content",
-				Required:            true,
+				MarkdownDescription: "The credentials file content. Defaults to the credentials file of the environment",
+				Optional:            true,
+				Validators: []validator.String{
+					encryptedContentValidator{},
+				},
+			},
+			"paths": schema.ListAttribute{
+				MarkdownDescription: "Only keep the keys under these paths, e.g. `aws` or `smtp.password`, so the other credentials do not end up in the state. A sequence that keeps only some of its items becomes an object by their index, e.g. `{\"1\" = ...}`",
+				ElementType:         types.StringType,
+				Optional:            true,
 			},
 			"content": schema.StringAttribute{
-				MarkdownDescription: "Decrypted credentials in YAML format",
+				MarkdownDescription: "Decrypted credentials in YAML format. With `paths`, only the selected keys, without the comments",
+				Computed:            true,
+				Sensitive:           true,
+			},
+			"values": schema.DynamicAttribute{
+				MarkdownDescription: "Decrypted credentials as an object, e.g. `values.aws.access_key_id`. Null, with a warning, if the credentials are YAML that Rails accepts but the provider can not parse, e.g. with duplicate keys",
+				Computed:            true,
+				Sensitive:           true,
+			},
+			"flat": schema.MapAttribute{
+				MarkdownDescription: "Decrypted credentials as strings by their dotted key path, e.g. `flat[\"aws.access_key_id\"]`. Null in the same cases as `values`",
+				ElementType:         types.StringType,
 				Computed:            true,
 				Sensitive:           true,
 			},
@@ -58,6 +95,13 @@ func (d *RailsCredentialsFileDataSource) Configure(ctx context.Context, req data
 	if req.ProviderData == nil {
 		return
 	}
+
+	config, ok := req.ProviderData.(*providerConfig)
+	if !ok {
+		resp.Diagnostics.AddError("Unexpected Data Source Configure Type", fmt.Sprintf("Expected *providerConfig, got: %T. Please report this issue to the provider developers.", req.ProviderData))
+		return
+	}
+	d.config = config
 }
 
 func (d *RailsCredentialsFileDataSource) Read(ctx context.Context, req datasource.ReadRequest, resp *datasource.ReadResponse) {
@@ -70,18 +114,67 @@ func (d *RailsCredentialsFileDataSource) Read(ctx context.Context, req datasourc
 		return
 	}
 
-	rawObject, err := credentials.Decrypt(credentials.SanitizeMasterKey(data.MasterKey.ValueString()), data.EncryptedContent.ValueString())
+	masterKey, encryptedContent, diags := d.config.resolveCredentials(data.Environment, data.MasterKey, data.EncryptedContent)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	rawString, err := decryptContent(masterKey, encryptedContent)
 	if err != nil {
 		resp.Diagnostics.AddError("Credentials decryption failed", err.Error())
 		return
 	}
-	rawString, err := credentials.UnmarshalSingleString(rawObject)
+	tree, err := credentials.ParseContent(rawString)
+	// Rails accepts some YAML our parser does not, e.g. duplicate keys, so only the attributes that need the parsed
+	// credentials fail
+	if err != nil && data.Paths.IsNull() {
+		resp.Diagnostics.AddWarning("Credentials parse failed", fmt.Sprintf("The decrypted credentials are not a valid YAML mapping, so values and flat are null: %s", err))
+		data.DecryptedContent = types.StringValue(rawString)
+		data.Values = types.DynamicNull()
+		data.Flat = types.MapNull(types.StringType)
+		resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+		return
+	}
 	if err != nil {
-		resp.Diagnostics.AddError("Credentials unmarshal failed", err.Error())
+		resp.Diagnostics.AddAttributeError(path.Root("paths"), "Credentials parse failed", fmt.Sprintf("The decrypted credentials are not a valid YAML mapping, so they can not be filtered by paths: %s", err))
 		return
 	}
+
+	if !data.Paths.IsNull() {
+		var paths []string
+		resp.Diagnostics.Append(data.Paths.ElementsAs(ctx, &paths, false)...)
+		if resp.Diagnostics.HasError() {
+			return
+		}
+		var prefixes [][]string
+		for _, p := range paths {
+			prefixes = append(prefixes, credentials.ParsePath(p))
+		}
+		filtered, ok := credentials.FilterTree(tree, prefixes)
+		if !ok {
+			resp.Diagnostics.AddAttributeError(path.Root("paths"), "Credentials not found", "None of the paths exist in the credentials.")
+			return
+		}
+		tree = filtered.(map[string]any)
+		rawString, err = credentials.EncodeContent(tree)
+		if err != nil {
+			resp.Diagnostics.AddError("Credentials encoding failed", err.Error())
+			return
+		}
+	}
 	data.DecryptedContent = types.StringValue(rawString)
 
+	values, diags := treeValue(tree)
+	resp.Diagnostics.Append(diags...)
+	flat, diags := flatValue(tree)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	data.Values = types.DynamicValue(values)
+	data.Flat = flat
+
 	// Save data into Terraform state
 	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
 }
@@ -0,0 +1,66 @@
+package provider
+
+import (
+	"context"
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// testReadFile runs Read of the file data source for the content encrypted with testMasterKey.
+func testReadFile(t *testing.T, content string, paths types.List) (RailsCredentialsFileDataSourceModel, diag.Diagnostics) {
+	ctx := context.Background()
+	d := &RailsCredentialsFileDataSource{}
+	schemaResp := datasource.SchemaResponse{}
+	d.Schema(ctx, datasource.SchemaRequest{}, &schemaResp)
+	s := schemaResp.Schema
+	null := tftypes.NewValue(s.Type().TerraformType(ctx), nil)
+
+	encrypted, err := encryptContent(testMasterKey, content)
+	assert.NoError(t, err)
+	config := RailsCredentialsFileDataSourceModel{
+		Environment:      types.StringNull(),
+		MasterKey:        types.StringValue(testMasterKey),
+		EncryptedContent: types.StringValue(encrypted),
+		Paths:            paths,
+		DecryptedContent: types.StringNull(),
+		Values:           types.DynamicNull(),
+		Flat:             types.MapNull(types.StringType),
+	}
+	// a config can not be set from a model, so it is set as a state first
+	configState := tfsdk.State{Schema: s, Raw: null}
+	assert.False(t, configState.Set(ctx, &config).HasError())
+
+	resp := datasource.ReadResponse{State: tfsdk.State{Schema: s, Raw: null}}
+	d.Read(ctx, datasource.ReadRequest{Config: tfsdk.Config{Schema: s, Raw: configState.Raw}}, &resp)
+	var ret RailsCredentialsFileDataSourceModel
+	if !resp.Diagnostics.HasError() {
+		resp.Diagnostics.Append(resp.State.Get(ctx, &ret)...)
+	}
+	return ret, resp.Diagnostics
+}
+
+func TestFileDataSourceRead(t *testing.T) {
+	data, diags := testReadFile(t, "aws:\n  key: a\n", types.ListNull(types.StringType))
+	assert.Empty(t, diags)
+	assert.Equal(t, types.StringValue("aws:\n  key: a\n"), data.DecryptedContent)
+	assert.Equal(t, types.MapValueMust(types.StringType, map[string]attr.Value{"aws.key": types.StringValue("a")}), data.Flat)
+
+	// Rails accepts duplicate keys, so the content is still read
+	duplicate := "aws:\n  key: a\naws:\n  key: b\n"
+	data, diags = testReadFile(t, duplicate, types.ListNull(types.StringType))
+	assert.False(t, diags.HasError())
+	assert.Equal(t, 1, diags.WarningsCount())
+	assert.Equal(t, types.StringValue(duplicate), data.DecryptedContent)
+	assert.True(t, data.Values.IsNull())
+	assert.True(t, data.Flat.IsNull())
+
+	// but can not be filtered
+	_, diags = testReadFile(t, duplicate, types.ListValueMust(types.StringType, []attr.Value{types.StringValue("aws")}))
+	assert.True(t, diags.HasError())
+}
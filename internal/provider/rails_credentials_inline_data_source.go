@@ -4,6 +4,7 @@ import (
 	"context"
 	"github.com/hashicorp/terraform-plugin-framework/datasource"
 	"github.com/hashicorp/terraform-plugin-framework/datasource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
 	"github.com/hashicorp/terraform-plugin-framework/types"
 	"github.com/jamesits/go-rails-credentials/pkg/credentials"
 )
@@ -23,6 +24,7 @@ type RailsCredentialsInlineDataSourceModel struct {
 	MasterKey        types.String `tfsdk:"master_key"`
 	EncryptedContent types.String `tfsdk:"encrypted_content"`
 	DecryptedContent types.String `tfsdk:"content"`
+	ValidateYAML     types.Bool   `tfsdk:"validate_yaml"`
 }
 
 func (d *RailsCredentialsInlineDataSource) Metadata(ctx context.Context, req datasource.MetadataRequest, resp *datasource.MetadataResponse) {
@@ -39,6 +41,9 @@ func (d *RailsCredentialsInlineDataSource) Schema(ctx context.Context, req datas
 				MarkdownDescription: "The master key",
 				Required:            true,
 				Sensitive:           true,
+				Validators: []validator.String{
+					masterKeyValidator{},
+				},
 			},
 			"encrypted_content": schema.StringAttribute{
 				MarkdownDescription: "The credentials file content",
@@ -48,6 +53,13 @@ func (d *RailsCredentialsInlineDataSource) Schema(ctx context.Context, req datas
 				MarkdownDescription: "Raw credentials in YAML format",
 				Required:            true,
 				Sensitive:           true,
+				Validators: []validator.String{
+					yamlContentValidator{},
+				},
+			},
+			"validate_yaml": schema.BoolAttribute{
+				MarkdownDescription: validateYAMLDescription,
+				Optional:            true,
 			},
 		},
 	}
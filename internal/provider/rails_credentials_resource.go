@@ -0,0 +1,386 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/jamesits/go-rails-credentials/pkg/credentials"
+	"strings"
+)
+
+// Ensure provider defined types fully satisfy framework interfaces.
+var _ resource.Resource = &RailsCredentialsResource{}
+var _ resource.ResourceWithModifyPlan = &RailsCredentialsResource{}
+var _ resource.ResourceWithImportState = &RailsCredentialsResource{}
+var _ resource.ResourceWithValidateConfig = &RailsCredentialsResource{}
+
+func NewRailsCredentialsResource() resource.Resource {
+	return &RailsCredentialsResource{}
+}
+
+// RailsCredentialsResource encrypts credentials and keeps the result in the state. Encryption uses a random IV, so
+// the ciphertext is only replaced when the plaintext or the key changes, rather than on every plan.
+//
+// The key and the plaintext can also be given as write-only attributes, which Terraform never stores. They are only
+// in the configuration, so they are read from there instead of the plan.
+type RailsCredentialsResource struct{}
+
+// RailsCredentialsResourceModel describes the resource data model.
+type RailsCredentialsResourceModel struct {
+	MasterKey          types.String `tfsdk:"master_key"`
+	MasterKeyWO        types.String `tfsdk:"master_key_wo"`
+	MasterKeyWOVersion types.Int64  `tfsdk:"master_key_wo_version"`
+	DecryptedContent   types.String `tfsdk:"content"`
+	ContentWO          types.String `tfsdk:"content_wo"`
+	ContentWOVersion   types.Int64  `tfsdk:"content_wo_version"`
+	ValidateYAML       types.Bool   `tfsdk:"validate_yaml"`
+	EncryptedContent   types.String `tfsdk:"encrypted_content"`
+	ChangedPaths       types.List   `tfsdk:"changed_paths"`
+}
+
+// effectiveMasterKey returns the master key from whichever of master_key and master_key_wo is set; config must be
+// read from the configuration for the write-only attribute to be there.
+func (m RailsCredentialsResourceModel) effectiveMasterKey(config RailsCredentialsResourceModel) types.String {
+	if !config.MasterKeyWO.IsNull() {
+		return config.MasterKeyWO
+	}
+	return m.MasterKey
+}
+
+// effectiveContent returns the plaintext from whichever of content and content_wo is set.
+func (m RailsCredentialsResourceModel) effectiveContent(config RailsCredentialsResourceModel) types.String {
+	if !config.ContentWO.IsNull() {
+		return config.ContentWO
+	}
+	return m.DecryptedContent
+}
+
+func (r *RailsCredentialsResource) Metadata(ctx context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_credentials"
+}
+
+func (r *RailsCredentialsResource) Schema(ctx context.Context, req resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		// This description is used by the documentation generator and the language server.
+		MarkdownDescription: "Encrypts a Rails credentials file. Unlike the `railscred_inline` data source, the encrypted content is kept in the state and only changes when `content` or `master_key` does.",
+
+		Attributes: map[string]schema.Attribute{
+			"master_key": schema.StringAttribute{
+				MarkdownDescription: "The master key. Exactly one of `master_key` and `master_key_wo` must be set",
+				Optional:            true,
+				Sensitive:           true,
+				Validators: []validator.String{
+					masterKeyValidator{},
+				},
+			},
+			"master_key_wo": schema.StringAttribute{
+				MarkdownDescription: "The master key, as a write-only attribute that is never stored in the state or plan (Terraform 1.11 or later). The state can then not be checked for drift",
+				Optional:            true,
+				Sensitive:           true,
+				WriteOnly:           true,
+				Validators: []validator.String{
+					masterKeyValidator{},
+				},
+			},
+			"master_key_wo_version": schema.Int64Attribute{
+				MarkdownDescription: "Change this to encrypt the content again, e.g. after rotating the `master_key_wo`",
+				Optional:            true,
+			},
+			"content": schema.StringAttribute{
+				MarkdownDescription: "Raw credentials in YAML format. Exactly one of `content` and `content_wo` must be set",
+				Optional:            true,
+				Sensitive:           true,
+				Validators: []validator.String{
+					yamlContentValidator{},
+				},
+			},
+			"content_wo": schema.StringAttribute{
+				MarkdownDescription: "Raw credentials in YAML format, as a write-only attribute that is never stored in the state or plan (Terraform 1.11 or later)",
+				Optional:            true,
+				Sensitive:           true,
+				WriteOnly:           true,
+				Validators: []validator.String{
+					yamlContentValidator{},
+				},
+			},
+			"content_wo_version": schema.Int64Attribute{
+				MarkdownDescription: "Change this to encrypt the content again, e.g. after changing the `content_wo`",
+				Optional:            true,
+			},
+			"validate_yaml": schema.BoolAttribute{
+				MarkdownDescription: validateYAMLDescription,
+				Optional:            true,
+			},
+			"encrypted_content": schema.StringAttribute{
+				MarkdownDescription: "The credentials file content",
+				Computed:            true,
+			},
+			"changed_paths": schema.ListAttribute{
+				MarkdownDescription: "The keys the last change of the content added (`+ path`), modified (`~ path`) or removed (`- path`), without their values, so the plan shows what changes. Null if that can not be told, e.g. the previous content is write-only and can not be decrypted with the key in the configuration",
+				ElementType:         types.StringType,
+				Computed:            true,
+			},
+		},
+	}
+}
+
+func (r *RailsCredentialsResource) Configure(ctx context.Context, req resource.ConfigureRequest, resp *resource.ConfigureResponse) {
+	// Prevent panic if the provider has not been configured.
+	if req.ProviderData == nil {
+		return
+	}
+}
+
+func (r *RailsCredentialsResource) ValidateConfig(ctx context.Context, req resource.ValidateConfigRequest, resp *resource.ValidateConfigResponse) {
+	var data RailsCredentialsResourceModel
+
+	resp.Diagnostics.Append(req.Config.Get(ctx, &data)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	for _, pair := range []struct {
+		name, woName string
+		value, wo    types.String
+	}{
+		{"master_key", "master_key_wo", data.MasterKey, data.MasterKeyWO},
+		{"content", "content_wo", data.DecryptedContent, data.ContentWO},
+	} {
+		// either may come from something not known yet
+		if pair.value.IsUnknown() || pair.wo.IsUnknown() {
+			continue
+		}
+		if pair.value.IsNull() == pair.wo.IsNull() {
+			resp.Diagnostics.AddAttributeError(path.Root(pair.name), "Invalid attribute combination", fmt.Sprintf("Exactly one of %s and %s must be set.", pair.name, pair.woName))
+		}
+	}
+}
+
+// ModifyPlan keeps the encrypted content from the state if it still decrypts to the planned content with the planned
+// key, and marks it unknown otherwise so it is encrypted again. Write-only attributes never show up as a difference in
+// the plan, so this is also what notices a changed master_key_wo or content_wo; changing a version attribute encrypts
+// again regardless, e.g. when the write-only values are not known until apply.
+//
+// Whenever the content is encrypted again, changed_paths lists the keys that change, so the plan shows more than
+// "(sensitive value)".
+func (r *RailsCredentialsResource) ModifyPlan(ctx context.Context, req resource.ModifyPlanRequest, resp *resource.ModifyPlanResponse) {
+	// nothing to do on destroy
+	if req.Plan.Raw.IsNull() {
+		return
+	}
+
+	var plan, config RailsCredentialsResourceModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	resp.Diagnostics.Append(req.Config.Get(ctx, &config)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	masterKey, content := plan.effectiveMasterKey(config), plan.effectiveContent(config)
+
+	if req.State.Raw.IsNull() {
+		r.planChangedPaths(ctx, nil, masterKey, content, resp)
+		return
+	}
+	var state RailsCredentialsResourceModel
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	if plan.MasterKeyWOVersion.Equal(state.MasterKeyWOVersion) && plan.ContentWOVersion.Equal(state.ContentWOVersion) {
+		// the framework already marked both unknown if anything else changed
+		if masterKey.IsUnknown() || content.IsUnknown() {
+			return
+		}
+		if !state.EncryptedContent.IsNull() {
+			rawString, err := decryptContent(masterKey.ValueString(), state.EncryptedContent.ValueString())
+			if err == nil && rawString == content.ValueString() {
+				resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, path.Root("encrypted_content"), state.EncryptedContent)...)
+				resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, path.Root("changed_paths"), state.ChangedPaths)...)
+				return
+			}
+		}
+	}
+	resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, path.Root("encrypted_content"), types.StringUnknown())...)
+	r.planChangedPaths(ctx, &state, masterKey, content, resp)
+}
+
+// planChangedPaths sets changed_paths in the plan, and warns about the changes, since the content itself is sensitive.
+func (r *RailsCredentialsResource) planChangedPaths(ctx context.Context, prior *RailsCredentialsResourceModel, masterKey types.String, content types.String, resp *resource.ModifyPlanResponse) {
+	paths, changes := changedPaths(prior, masterKey, content)
+	resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, path.Root("changed_paths"), paths)...)
+	if len(changes) == 0 {
+		return
+	}
+
+	detail := strings.Builder{}
+	detail.WriteString("The planned content changes these keys of the credentials (values are not shown):\n")
+	for _, p := range paths.Elements() {
+		detail.WriteString("\n  " + p.(types.String).ValueString())
+	}
+	resp.Diagnostics.AddWarning("Credentials keys will change", detail.String())
+}
+
+// changedPaths compares the keys of the prior content with the planned content, as "+ path", "~ path" or "- path" for
+// added, modified and removed keys. The list is unknown if the planned content is not known yet, and null if the
+// change can not be told, e.g. the previous content can not be decrypted or is not YAML.
+func changedPaths(prior *RailsCredentialsResourceModel, masterKey types.String, content types.String) (types.List, []credentials.Change) {
+	if masterKey.IsUnknown() || content.IsUnknown() {
+		return types.ListUnknown(types.StringType), nil
+	}
+
+	from := map[string]any{}
+	if prior != nil {
+		priorContent, ok := prior.priorContent(masterKey)
+		if !ok {
+			return types.ListNull(types.StringType), nil
+		}
+		var err error
+		from, err = credentials.ParseContent(priorContent)
+		if err != nil {
+			return types.ListNull(types.StringType), nil
+		}
+	}
+	to, err := credentials.ParseContent(content.ValueString())
+	if err != nil {
+		return types.ListNull(types.StringType), nil
+	}
+
+	changes := credentials.Diff(from, to)
+	elems := make([]attr.Value, 0, len(changes))
+	for _, c := range changes {
+		elems = append(elems, types.StringValue(c.Kind.Symbol()+" "+c.Key()))
+	}
+	return types.ListValueMust(types.StringType, elems), changes
+}
+
+// priorContent returns the plaintext in the state: the content, or what the encrypted content decrypts to with the
+// previous or the planned key when the content is write-only.
+func (m RailsCredentialsResourceModel) priorContent(masterKey types.String) (string, bool) {
+	if !m.DecryptedContent.IsNull() {
+		return m.DecryptedContent.ValueString(), true
+	}
+	if m.EncryptedContent.IsNull() {
+		return "", false
+	}
+	for _, k := range []types.String{m.MasterKey, masterKey} {
+		if k.IsNull() || k.IsUnknown() {
+			continue
+		}
+		rawString, err := decryptContent(k.ValueString(), m.EncryptedContent.ValueString())
+		if err == nil {
+			return rawString, true
+		}
+	}
+	return "", false
+}
+
+func (r *RailsCredentialsResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	var data, config RailsCredentialsResourceModel
+
+	// Read Terraform plan data into the model
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &data)...)
+	resp.Diagnostics.Append(req.Config.Get(ctx, &config)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	encryptedString, err := encryptContent(data.effectiveMasterKey(config).ValueString(), data.effectiveContent(config).ValueString())
+	if err != nil {
+		resp.Diagnostics.AddError("Credentials encryption failed", err.Error())
+		return
+	}
+	data.EncryptedContent = types.StringValue(encryptedString)
+	// the content was not known at plan time
+	if data.ChangedPaths.IsUnknown() {
+		data.ChangedPaths, _ = changedPaths(nil, data.effectiveMasterKey(config), data.effectiveContent(config))
+	}
+
+	// Save data into Terraform state
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+// Read detects drift: if the encrypted content in the state no longer decrypts to the content in the state, the
+// content is replaced with what it actually decrypts to (or removed if it can not be decrypted), so the next plan
+// encrypts it again.
+func (r *RailsCredentialsResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	var data RailsCredentialsResourceModel
+
+	// Read Terraform prior state data into the model
+	resp.Diagnostics.Append(req.State.Get(ctx, &data)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	// right after an import, the key is not known yet; write-only keys and content are never in the state, and the
+	// plaintext must not end up there either
+	if data.MasterKey.IsNull() || data.DecryptedContent.IsNull() || data.EncryptedContent.IsNull() {
+		return
+	}
+
+	rawString, err := decryptContent(data.MasterKey.ValueString(), data.EncryptedContent.ValueString())
+	if err != nil {
+		resp.Diagnostics.AddWarning("Credentials decryption failed", "The encrypted content in the state can not be decrypted with the master key and will be encrypted again: "+err.Error())
+		data.DecryptedContent = types.StringNull()
+	} else {
+		data.DecryptedContent = types.StringValue(rawString)
+	}
+
+	// Save updated data into Terraform state
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+func (r *RailsCredentialsResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	var data, config, state RailsCredentialsResourceModel
+
+	// Read Terraform plan data into the model
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &data)...)
+	resp.Diagnostics.Append(req.Config.Get(ctx, &config)...)
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	// ModifyPlan kept the encrypted content if it is still valid
+	if data.EncryptedContent.IsUnknown() {
+		encryptedString, err := encryptContent(data.effectiveMasterKey(config).ValueString(), data.effectiveContent(config).ValueString())
+		if err != nil {
+			resp.Diagnostics.AddError("Credentials encryption failed", err.Error())
+			return
+		}
+		data.EncryptedContent = types.StringValue(encryptedString)
+	}
+	// the content was not known at plan time
+	if data.ChangedPaths.IsUnknown() {
+		data.ChangedPaths, _ = changedPaths(&state, data.effectiveMasterKey(config), data.effectiveContent(config))
+	}
+
+	// Save updated data into Terraform state
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+func (r *RailsCredentialsResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	// the encrypted content only exists in the state
+}
+
+// ImportState takes the content of an existing encrypted credentials file, e.g.
+// `terraform import railscred_credentials.example "$(cat config/credentials.yml.enc)"`.
+// The key and the plaintext come from the configuration on the next apply; the imported ciphertext is kept if it
+// matches them.
+func (r *RailsCredentialsResource) ImportState(ctx context.Context, req resource.ImportStateRequest, resp *resource.ImportStateResponse) {
+	_, err := credentials.ParseEnvelope(req.ID)
+	if err != nil {
+		resp.Diagnostics.AddError("Invalid encrypted credentials", "The import ID must be the content of an encrypted credentials file: "+err.Error())
+		return
+	}
+	resource.ImportStatePassthroughID(ctx, path.Root("encrypted_content"), req, resp)
+}
@@ -0,0 +1,194 @@
+package provider
+
+import (
+	"context"
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+const (
+	testMasterKey      = "0123456789abcdef0123456789abcdef"
+	testOtherMasterKey = "fedcba9876543210fedcba9876543210"
+)
+
+func testChangedPaths(paths ...string) types.List {
+	elems := make([]attr.Value, 0, len(paths))
+	for _, p := range paths {
+		elems = append(elems, types.StringValue(p))
+	}
+	return types.ListValueMust(types.StringType, elems)
+}
+
+// testCredentialsModel returns a model with every attribute null.
+func testCredentialsModel() RailsCredentialsResourceModel {
+	return RailsCredentialsResourceModel{
+		MasterKey:          types.StringNull(),
+		MasterKeyWO:        types.StringNull(),
+		MasterKeyWOVersion: types.Int64Null(),
+		DecryptedContent:   types.StringNull(),
+		ContentWO:          types.StringNull(),
+		ContentWOVersion:   types.Int64Null(),
+		ValidateYAML:       types.BoolNull(),
+		EncryptedContent:   types.StringNull(),
+		ChangedPaths:       types.ListNull(types.StringType),
+	}
+}
+
+// testCredentialsState returns the state after the content was encrypted with the key, like Create would leave it.
+func testCredentialsState(t *testing.T, masterKey string, content string, writeOnly bool) RailsCredentialsResourceModel {
+	encrypted, err := encryptContent(masterKey, content)
+	assert.NoError(t, err)
+
+	m := testCredentialsModel()
+	m.EncryptedContent = types.StringValue(encrypted)
+	m.ChangedPaths = testChangedPaths()
+	if writeOnly {
+		m.MasterKeyWOVersion = types.Int64Value(1)
+		m.ContentWOVersion = types.Int64Value(1)
+	} else {
+		m.MasterKey = types.StringValue(masterKey)
+		m.DecryptedContent = types.StringValue(content)
+	}
+	return m
+}
+
+func TestChangedPaths(t *testing.T) {
+	// create: everything is added
+	paths, changes := changedPaths(nil, types.StringValue(testMasterKey), types.StringValue("a: 1\nb:\n  c: 2\n"))
+	assert.Equal(t, testChangedPaths("+ a", "+ b.c"), paths)
+	assert.Len(t, changes, 2)
+
+	// update: values are never shown
+	prior := testCredentialsState(t, testMasterKey, "a: 1\nb:\n  c: secret\n", false)
+	paths, _ = changedPaths(&prior, types.StringValue(testMasterKey), types.StringValue("a: 2\nb:\n  d: secret\n"))
+	assert.Equal(t, testChangedPaths("~ a", "- b.c", "+ b.d"), paths)
+
+	paths, changes = changedPaths(&prior, types.StringValue(testMasterKey), types.StringValue("a: 1\nb:\n  c: secret\n"))
+	assert.Equal(t, testChangedPaths(), paths)
+	assert.Empty(t, changes)
+
+	// write-only: the prior content is decrypted from the state, with the planned key
+	prior = testCredentialsState(t, testMasterKey, "a: 1\n", true)
+	paths, _ = changedPaths(&prior, types.StringValue(testMasterKey), types.StringValue("a: 1\nb: 2\n"))
+	assert.Equal(t, testChangedPaths("+ b"), paths)
+
+	// the prior content can not be told
+	paths, changes = changedPaths(&prior, types.StringValue(testOtherMasterKey), types.StringValue("a: 1\n"))
+	assert.True(t, paths.IsNull())
+	assert.Empty(t, changes)
+
+	paths, _ = changedPaths(&prior, types.StringValue(testMasterKey), types.StringValue("- not a mapping\n"))
+	assert.True(t, paths.IsNull())
+
+	// not known until apply
+	paths, _ = changedPaths(&prior, types.StringValue(testMasterKey), types.StringUnknown())
+	assert.True(t, paths.IsUnknown())
+	paths, _ = changedPaths(&prior, types.StringUnknown(), types.StringValue("a: 1\n"))
+	assert.True(t, paths.IsUnknown())
+}
+
+// testModifyPlan runs ModifyPlan for the planned and configured values over the state, or on create if the state is
+// nil, and returns the new plan.
+func testModifyPlan(t *testing.T, state *RailsCredentialsResourceModel, plan RailsCredentialsResourceModel, config RailsCredentialsResourceModel) (RailsCredentialsResourceModel, diag.Diagnostics) {
+	ctx := context.Background()
+	r := &RailsCredentialsResource{}
+	schemaResp := resource.SchemaResponse{}
+	r.Schema(ctx, resource.SchemaRequest{}, &schemaResp)
+	s := schemaResp.Schema
+	null := tftypes.NewValue(s.Type().TerraformType(ctx), nil)
+
+	req := resource.ModifyPlanRequest{
+		State: tfsdk.State{Schema: s, Raw: null},
+		Plan:  tfsdk.Plan{Schema: s, Raw: null},
+	}
+	// a config can not be set from a model, so it is set as a state first
+	configState := tfsdk.State{Schema: s, Raw: null}
+	var diags diag.Diagnostics
+	if state != nil {
+		diags.Append(req.State.Set(ctx, state)...)
+	}
+	diags.Append(req.Plan.Set(ctx, &plan)...)
+	diags.Append(configState.Set(ctx, &config)...)
+	assert.False(t, diags.HasError(), diags)
+	req.Config = tfsdk.Config{Schema: s, Raw: configState.Raw}
+
+	resp := resource.ModifyPlanResponse{Plan: req.Plan}
+	r.ModifyPlan(ctx, req, &resp)
+
+	var ret RailsCredentialsResourceModel
+	resp.Diagnostics.Append(resp.Plan.Get(ctx, &ret)...)
+	assert.False(t, resp.Diagnostics.HasError(), resp.Diagnostics)
+	return ret, resp.Diagnostics
+}
+
+func TestModifyPlanWriteOnly(t *testing.T) {
+	state := testCredentialsState(t, testMasterKey, "a: 1\n", true)
+	modifyPlan := func(masterKey string, content string, masterKeyVersion int64, contentVersion int64) (RailsCredentialsResourceModel, diag.Diagnostics) {
+		plan := state
+		plan.MasterKeyWOVersion = types.Int64Value(masterKeyVersion)
+		plan.ContentWOVersion = types.Int64Value(contentVersion)
+		config := testCredentialsModel()
+		config.MasterKeyWO = types.StringValue(masterKey)
+		config.ContentWO = types.StringValue(content)
+		config.MasterKeyWOVersion = plan.MasterKeyWOVersion
+		config.ContentWOVersion = plan.ContentWOVersion
+		return testModifyPlan(t, &state, plan, config)
+	}
+
+	// unchanged: the ciphertext is kept
+	plan, diags := modifyPlan(testMasterKey, "a: 1\n", 1, 1)
+	assert.Equal(t, state.EncryptedContent, plan.EncryptedContent)
+	assert.Equal(t, state.ChangedPaths, plan.ChangedPaths)
+	assert.Empty(t, diags)
+
+	// a changed write-only value is noticed without a version change
+	plan, diags = modifyPlan(testMasterKey, "a: 2\n", 1, 1)
+	assert.True(t, plan.EncryptedContent.IsUnknown())
+	assert.Equal(t, testChangedPaths("~ a"), plan.ChangedPaths)
+	assert.Equal(t, 1, diags.WarningsCount())
+
+	// a new version encrypts again, even with the same values
+	plan, diags = modifyPlan(testMasterKey, "a: 1\n", 1, 2)
+	assert.True(t, plan.EncryptedContent.IsUnknown())
+	assert.Equal(t, testChangedPaths(), plan.ChangedPaths)
+	assert.Empty(t, diags)
+
+	// a rotated key can not decrypt the prior content
+	plan, _ = modifyPlan(testOtherMasterKey, "a: 1\n", 2, 1)
+	assert.True(t, plan.EncryptedContent.IsUnknown())
+	assert.True(t, plan.ChangedPaths.IsNull())
+}
+
+func TestModifyPlanContent(t *testing.T) {
+	state := testCredentialsState(t, testMasterKey, "a: 1\n", false)
+	config := testCredentialsModel()
+	config.MasterKey = state.MasterKey
+	config.DecryptedContent = state.DecryptedContent
+
+	plan, diags := testModifyPlan(t, &state, state, config)
+	assert.Equal(t, state.EncryptedContent, plan.EncryptedContent)
+	assert.Empty(t, diags)
+
+	// the framework marks computed attributes unknown when the content changes
+	changed := state
+	changed.DecryptedContent = types.StringValue("b: secret\n")
+	changed.EncryptedContent = types.StringUnknown()
+	changed.ChangedPaths = types.ListUnknown(types.StringType)
+	config.DecryptedContent = changed.DecryptedContent
+	plan, diags = testModifyPlan(t, &state, changed, config)
+	assert.True(t, plan.EncryptedContent.IsUnknown())
+	assert.Equal(t, testChangedPaths("- a", "+ b"), plan.ChangedPaths)
+	assert.Equal(t, 1, diags.WarningsCount())
+	assert.NotContains(t, diags[0].Detail(), "secret")
+
+	// create
+	plan, _ = testModifyPlan(t, nil, changed, config)
+	assert.True(t, plan.EncryptedContent.IsUnknown())
+	assert.Equal(t, testChangedPaths("+ b"), plan.ChangedPaths)
+}
@@ -2,29 +2,41 @@ package provider
 
 import (
 	"context"
+	"fmt"
 	"github.com/hashicorp/terraform-plugin-framework/path"
 	"github.com/hashicorp/terraform-plugin-framework/resource"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/mapplanmodifier"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
 	"github.com/hashicorp/terraform-plugin-framework/types"
 	"github.com/jamesits/go-rails-credentials/pkg/credentials"
+	"time"
 )
 
 // Ensure provider defined types fully satisfy framework interfaces.
 var _ resource.Resource = &RailsMasterKeyResource{}
 var _ resource.ResourceWithImportState = &RailsMasterKeyResource{}
+var _ resource.ResourceWithModifyPlan = &RailsMasterKeyResource{}
+var _ resource.ResourceWithValidateConfig = &RailsMasterKeyResource{}
 
 func NewRailsMasterKeyResource() resource.Resource {
 	return &RailsMasterKeyResource{}
 }
 
-// RailsMasterKeyResource defines the resource implementation.
+// RailsMasterKeyResource defines the resource implementation. The key only exists in the state; it is replaced when
+// the keepers change or, with rotation_days, when it gets old.
 type RailsMasterKeyResource struct{}
 
 // RailsMasterKeyResourceModel describes the resource data model.
 type RailsMasterKeyResourceModel struct {
-	MasterKey types.String `tfsdk:"master_key"`
+	MasterKey    types.String `tfsdk:"master_key"`
+	Keepers      types.Map    `tfsdk:"keepers"`
+	RotationDays types.Int64  `tfsdk:"rotation_days"`
+	CreatedAt    types.String `tfsdk:"created_at"`
+	RotatesAt    types.String `tfsdk:"rotates_at"`
+	Expired      types.Bool   `tfsdk:"expired"`
+	Fingerprint  types.String `tfsdk:"fingerprint"`
 }
 
 func (r *RailsMasterKeyResource) Metadata(ctx context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
@@ -43,6 +55,47 @@ func (r *RailsMasterKeyResource) Schema(ctx context.Context, req resource.Schema
 				Computed:  true,
 				Sensitive: true,
 
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+			"keepers": schema.MapAttribute{
+				MarkdownDescription: "Arbitrary values that generate a new key when they change, like the `keepers` of the `random` provider. Setting them on a key that has none, e.g. after an import, keeps the key",
+				ElementType:         types.StringType,
+				Optional:            true,
+
+				PlanModifiers: []planmodifier.Map{
+					mapplanmodifier.RequiresReplaceIf(
+						requiresReplaceIfStateNotNull,
+						"Changing the keepers generates a new key, unless the key had none, e.g. after an import.",
+						"Changing the keepers generates a new key, unless the key had none, e.g. after an import.",
+					),
+				},
+			},
+			"rotation_days": schema.Int64Attribute{
+				MarkdownDescription: "Generate a new key once the key is this many days old, i.e. on the first plan after a refresh has found `rotates_at` passed",
+				Optional:            true,
+			},
+			"created_at": schema.StringAttribute{
+				MarkdownDescription: "When the key was generated or imported, in RFC 3339 format",
+				Computed:            true,
+
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+			"rotates_at": schema.StringAttribute{
+				MarkdownDescription: "When the key is rotated with `rotation_days`, in RFC 3339 format. Null without `rotation_days`",
+				Computed:            true,
+			},
+			"expired": schema.BoolAttribute{
+				MarkdownDescription: "Whether `rotates_at` had passed at the last refresh. The next plan then replaces the key",
+				Computed:            true,
+			},
+			"fingerprint": schema.StringAttribute{
+				MarkdownDescription: "A short identifier of the key that is safe to show, e.g. to tell which key a file is encrypted with",
+				Computed:            true,
+
 				PlanModifiers: []planmodifier.String{
 					stringplanmodifier.UseStateForUnknown(),
 				},
@@ -51,6 +104,12 @@ func (r *RailsMasterKeyResource) Schema(ctx context.Context, req resource.Schema
 	}
 }
 
+// requiresReplaceIfStateNotNull replaces the key when the keepers change, but not when they are set for the first time:
+// an imported key has none in the state, and replacing it would lose the files encrypted with it.
+func requiresReplaceIfStateNotNull(ctx context.Context, req planmodifier.MapRequest, resp *mapplanmodifier.RequiresReplaceIfFuncResponse) {
+	resp.RequiresReplace = !req.StateValue.IsNull()
+}
+
 func (r *RailsMasterKeyResource) Configure(ctx context.Context, req resource.ConfigureRequest, resp *resource.ConfigureResponse) {
 	// Prevent panic if the provider has not been configured.
 	if req.ProviderData == nil {
@@ -58,6 +117,80 @@ func (r *RailsMasterKeyResource) Configure(ctx context.Context, req resource.Con
 	}
 }
 
+func (r *RailsMasterKeyResource) ValidateConfig(ctx context.Context, req resource.ValidateConfigRequest, resp *resource.ValidateConfigResponse) {
+	var data RailsMasterKeyResourceModel
+
+	resp.Diagnostics.Append(req.Config.Get(ctx, &data)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	if !data.RotationDays.IsNull() && !data.RotationDays.IsUnknown() && data.RotationDays.ValueInt64() < 1 {
+		resp.Diagnostics.AddAttributeError(path.Root("rotation_days"), "Invalid rotation period", "rotation_days must be at least 1.")
+	}
+}
+
+// ModifyPlan plans rotates_at from created_at and rotation_days, and replaces the key once Read has marked it as
+// expired. It does not depend on the current time, so the plan is the same when a saved plan is applied, and the old
+// key stays in the state until the replacement is applied.
+func (r *RailsMasterKeyResource) ModifyPlan(ctx context.Context, req resource.ModifyPlanRequest, resp *resource.ModifyPlanResponse) {
+	// nothing to do on destroy
+	if req.Plan.Raw.IsNull() {
+		return
+	}
+
+	var plan RailsMasterKeyResourceModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	plan.Expired = types.BoolValue(false)
+	if !req.State.Raw.IsNull() {
+		var state RailsMasterKeyResourceModel
+		resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+		if resp.Diagnostics.HasError() {
+			return
+		}
+
+		if state.Expired.ValueBool() {
+			plan.MasterKey = types.StringUnknown()
+			plan.CreatedAt = types.StringUnknown()
+			plan.Fingerprint = types.StringUnknown()
+			resp.RequiresReplace = append(resp.RequiresReplace, path.Root("expired"))
+		} else if !state.Expired.IsNull() {
+			plan.Expired = state.Expired
+		}
+	}
+
+	var err error
+	plan.RotatesAt, err = rotationDeadline(plan.CreatedAt, plan.RotationDays)
+	if err != nil {
+		resp.Diagnostics.AddAttributeWarning(path.Root("created_at"), "Invalid creation time", fmt.Sprintf("The key can not be rotated: %s", err))
+		plan.RotatesAt = types.StringNull()
+	}
+	resp.Diagnostics.Append(resp.Plan.Set(ctx, &plan)...)
+}
+
+// rotationDeadline returns when a key created at createdAt is rotated, or null without a rotation period.
+func rotationDeadline(createdAt types.String, rotationDays types.Int64) (types.String, error) {
+	if rotationDays.IsNull() {
+		return types.StringNull(), nil
+	}
+	if createdAt.IsUnknown() || rotationDays.IsUnknown() {
+		return types.StringUnknown(), nil
+	}
+	if createdAt.IsNull() {
+		return types.StringNull(), nil
+	}
+	t, err := time.Parse(time.RFC3339, createdAt.ValueString())
+	if err != nil {
+		return types.StringNull(), err
+	}
+	return types.StringValue(t.AddDate(0, 0, int(rotationDays.ValueInt64())).Format(time.RFC3339)), nil
+}
+
 func (r *RailsMasterKeyResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
 	var data RailsMasterKeyResourceModel
 
@@ -74,23 +207,53 @@ func (r *RailsMasterKeyResource) Create(ctx context.Context, req resource.Create
 		return
 	}
 	data.MasterKey = types.StringValue(m)
+	data.CreatedAt = types.StringValue(time.Now().UTC().Format(time.RFC3339))
+	data.Fingerprint = types.StringValue(credentials.KeyFingerprint(m))
+	data.RotatesAt, _ = rotationDeadline(data.CreatedAt, data.RotationDays)
+	data.Expired = types.BoolValue(false)
 
 	// Save data into Terraform state
 	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
 }
 
+// Read fills in the attributes that states written by earlier versions do not have. The rotation period of such keys
+// starts at the first refresh, since their actual age is not known.
+//
+// Once rotates_at has passed, the key is marked as expired; it stays in the state until ModifyPlan has replaced it.
 func (r *RailsMasterKeyResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
-	//var data RailsMasterKeyResourceModel
-	//
-	//// Read Terraform prior state data into the model
-	//resp.Diagnostics.Append(req.State.Get(ctx, &data)...)
-	//
-	//if resp.Diagnostics.HasError() {
-	//	return
-	//}
+	var data RailsMasterKeyResourceModel
+
+	// Read Terraform prior state data into the model
+	resp.Diagnostics.Append(req.State.Get(ctx, &data)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	if data.CreatedAt.IsNull() {
+		data.CreatedAt = types.StringValue(time.Now().UTC().Format(time.RFC3339))
+	}
+	data.Fingerprint = types.StringValue(credentials.KeyFingerprint(data.MasterKey.ValueString()))
+	if data.RotatesAt.IsNull() {
+		var err error
+		data.RotatesAt, err = rotationDeadline(data.CreatedAt, data.RotationDays)
+		if err != nil {
+			resp.Diagnostics.AddAttributeWarning(path.Root("created_at"), "Invalid creation time", fmt.Sprintf("The key can not be rotated: %s", err))
+		}
+	}
+
+	data.Expired = types.BoolValue(false)
+	if !data.RotatesAt.IsNull() {
+		rotatesAt, err := time.Parse(time.RFC3339, data.RotatesAt.ValueString())
+		if err != nil {
+			resp.Diagnostics.AddAttributeWarning(path.Root("rotates_at"), "Invalid rotation time", fmt.Sprintf("The key can not be rotated: %s", err))
+		} else {
+			data.Expired = types.BoolValue(!time.Now().Before(rotatesAt))
+		}
+	}
 
 	// Save updated data into Terraform state
-	//resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
 }
 
 func (r *RailsMasterKeyResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
@@ -108,16 +271,19 @@ func (r *RailsMasterKeyResource) Update(ctx context.Context, req resource.Update
 }
 
 func (r *RailsMasterKeyResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
-	//var data RailsMasterKeyResourceModel
-
-	// Read Terraform prior state data into the model
-	//resp.Diagnostics.Append(req.State.Get(ctx, &data)...)
-	//
-	//if resp.Diagnostics.HasError() {
-	//	return
-	//}
+	// the master key only exists in the state
 }
 
+// ImportState takes an existing master key, e.g. `terraform import railscred_master_key.example "$(cat config/master.key)"`.
 func (r *RailsMasterKeyResource) ImportState(ctx context.Context, req resource.ImportStateRequest, resp *resource.ImportStateResponse) {
-	resource.ImportStatePassthroughID(ctx, path.Root("master_key"), req, resp)
+	m := credentials.SanitizeMasterKey(req.ID)
+	err := credentials.ValidateMasterKey(m)
+	if err != nil {
+		resp.Diagnostics.AddError("Invalid master key", "The import ID must be a Rails master key: "+err.Error())
+		return
+	}
+
+	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root("master_key"), m)...)
+	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root("created_at"), time.Now().UTC().Format(time.RFC3339))...)
+	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root("fingerprint"), credentials.KeyFingerprint(m))...)
 }
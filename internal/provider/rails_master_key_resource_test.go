@@ -0,0 +1,122 @@
+package provider
+
+import (
+	"context"
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/mapplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+	"github.com/jamesits/go-rails-credentials/pkg/credentials"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestRotationDeadline(t *testing.T) {
+	createdAt := types.StringValue("2024-01-31T12:00:00Z")
+
+	deadline, err := rotationDeadline(createdAt, types.Int64Value(30))
+	assert.NoError(t, err)
+	assert.Equal(t, types.StringValue("2024-03-01T12:00:00Z"), deadline)
+
+	deadline, err = rotationDeadline(createdAt, types.Int64Null())
+	assert.NoError(t, err)
+	assert.True(t, deadline.IsNull())
+
+	// on create
+	deadline, err = rotationDeadline(types.StringUnknown(), types.Int64Value(30))
+	assert.NoError(t, err)
+	assert.True(t, deadline.IsUnknown())
+
+	_, err = rotationDeadline(types.StringValue("yesterday"), types.Int64Value(30))
+	assert.Error(t, err)
+}
+
+func TestRequiresReplaceIfStateNotNull(t *testing.T) {
+	keepers := types.MapValueMust(types.StringType, map[string]attr.Value{"environment": types.StringValue("production")})
+
+	// after an import
+	resp := mapplanmodifier.RequiresReplaceIfFuncResponse{}
+	requiresReplaceIfStateNotNull(context.Background(), planmodifier.MapRequest{StateValue: types.MapNull(types.StringType), PlanValue: keepers}, &resp)
+	assert.False(t, resp.RequiresReplace)
+
+	resp = mapplanmodifier.RequiresReplaceIfFuncResponse{}
+	requiresReplaceIfStateNotNull(context.Background(), planmodifier.MapRequest{StateValue: types.MapValueMust(types.StringType, map[string]attr.Value{}), PlanValue: keepers}, &resp)
+	assert.True(t, resp.RequiresReplace)
+}
+
+func testMasterKeyState(t *testing.T, createdAt string, expired bool) tfsdk.State {
+	ctx := context.Background()
+	schemaResp := resource.SchemaResponse{}
+	(&RailsMasterKeyResource{}).Schema(ctx, resource.SchemaRequest{}, &schemaResp)
+	state := tfsdk.State{Schema: schemaResp.Schema, Raw: tftypes.NewValue(schemaResp.Schema.Type().TerraformType(ctx), nil)}
+
+	deadline, err := rotationDeadline(types.StringValue(createdAt), types.Int64Value(30))
+	assert.NoError(t, err)
+	diags := state.Set(ctx, &RailsMasterKeyResourceModel{
+		MasterKey:    types.StringValue(testMasterKey),
+		Keepers:      types.MapNull(types.StringType),
+		RotationDays: types.Int64Value(30),
+		CreatedAt:    types.StringValue(createdAt),
+		RotatesAt:    deadline,
+		Expired:      types.BoolValue(expired),
+		Fingerprint:  types.StringValue(credentials.KeyFingerprint(testMasterKey)),
+	})
+	assert.False(t, diags.HasError(), diags)
+	return state
+}
+
+func TestReadMarksExpiredKey(t *testing.T) {
+	ctx := context.Background()
+	r := &RailsMasterKeyResource{}
+
+	for _, tc := range []struct {
+		createdAt string
+		expired   bool
+	}{
+		{createdAt: "2024-01-31T12:00:00Z", expired: true},
+		{createdAt: "2999-01-31T12:00:00Z", expired: false},
+	} {
+		state := testMasterKeyState(t, tc.createdAt, false)
+		resp := resource.ReadResponse{State: state}
+		r.Read(ctx, resource.ReadRequest{State: state}, &resp)
+		assert.False(t, resp.Diagnostics.HasError(), resp.Diagnostics)
+
+		var data RailsMasterKeyResourceModel
+		resp.Diagnostics.Append(resp.State.Get(ctx, &data)...)
+		assert.False(t, resp.Diagnostics.HasError(), resp.Diagnostics)
+		// the key is kept until the replacement is applied
+		assert.Equal(t, types.StringValue(testMasterKey), data.MasterKey)
+		assert.Equal(t, types.BoolValue(tc.expired), data.Expired, tc.createdAt)
+	}
+}
+
+func TestModifyPlanReplacesExpiredKey(t *testing.T) {
+	ctx := context.Background()
+	r := &RailsMasterKeyResource{}
+
+	for _, expired := range []bool{true, false} {
+		state := testMasterKeyState(t, "2024-01-31T12:00:00Z", expired)
+		plan := tfsdk.Plan{Schema: state.Schema, Raw: state.Raw}
+		resp := resource.ModifyPlanResponse{Plan: plan}
+		r.ModifyPlan(ctx, resource.ModifyPlanRequest{State: state, Plan: plan}, &resp)
+		assert.False(t, resp.Diagnostics.HasError(), resp.Diagnostics)
+
+		var data RailsMasterKeyResourceModel
+		resp.Diagnostics.Append(resp.Plan.Get(ctx, &data)...)
+		assert.False(t, resp.Diagnostics.HasError(), resp.Diagnostics)
+		assert.False(t, data.Expired.ValueBool())
+		if expired {
+			assert.Equal(t, path.Paths{path.Root("expired")}, resp.RequiresReplace)
+			assert.True(t, data.MasterKey.IsUnknown())
+			assert.True(t, data.RotatesAt.IsUnknown())
+		} else {
+			assert.Empty(t, resp.RequiresReplace)
+			assert.Equal(t, types.StringValue(testMasterKey), data.MasterKey)
+			assert.Equal(t, types.StringValue("2024-03-01T12:00:00Z"), data.RotatesAt)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package provider
+
+import (
+	"context"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/mapplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/jamesits/go-rails-credentials/pkg/credentials"
+)
+
+// Ensure provider defined types fully satisfy framework interfaces.
+var _ resource.Resource = &RailsSecretKeyBaseResource{}
+
+func NewRailsSecretKeyBaseResource() resource.Resource {
+	return &RailsSecretKeyBaseResource{}
+}
+
+// RailsSecretKeyBaseResource generates a secret_key_base, which only exists in the state.
+type RailsSecretKeyBaseResource struct{}
+
+// RailsSecretKeyBaseResourceModel describes the resource data model.
+type RailsSecretKeyBaseResourceModel struct {
+	Keepers       types.Map    `tfsdk:"keepers"`
+	SecretKeyBase types.String `tfsdk:"secret_key_base"`
+}
+
+func (r *RailsSecretKeyBaseResource) Metadata(ctx context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_secret_key_base"
+}
+
+func (r *RailsSecretKeyBaseResource) Schema(ctx context.Context, req resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		// This description is used by the documentation generator and the language server.
+		MarkdownDescription: "Random-generated Rails `secret_key_base`, like `bin/rails secret`",
+
+		Attributes: map[string]schema.Attribute{
+			"keepers": schema.MapAttribute{
+				MarkdownDescription: "Arbitrary values that generate a new secret when they change, like the `keepers` of the `random` provider",
+				ElementType:         types.StringType,
+				Optional:            true,
+
+				PlanModifiers: []planmodifier.Map{
+					mapplanmodifier.RequiresReplace(),
+				},
+			},
+			"secret_key_base": schema.StringAttribute{
+				MarkdownDescription: "The secret, 128 hex characters",
+
+				Computed:  true,
+				Sensitive: true,
+
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+		},
+	}
+}
+
+func (r *RailsSecretKeyBaseResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	var data RailsSecretKeyBaseResourceModel
+
+	// Read Terraform plan data into the model
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &data)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	s, err := credentials.RandomSecretKeyBase()
+	if err != nil {
+		resp.Diagnostics.AddError("unable to generate secret_key_base", err.Error())
+		return
+	}
+	data.SecretKeyBase = types.StringValue(s)
+
+	// Save data into Terraform state
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+func (r *RailsSecretKeyBaseResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	// the secret only exists in the state
+}
+
+func (r *RailsSecretKeyBaseResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	// every attribute either requires replacement or is computed, so there is nothing to update
+	var data RailsSecretKeyBaseResourceModel
+
+	// Read Terraform plan data into the model
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &data)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	// Save updated data into Terraform state
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+func (r *RailsSecretKeyBaseResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	// the secret only exists in the state
+}
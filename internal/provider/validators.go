@@ -0,0 +1,101 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/jamesits/go-rails-credentials/pkg/credentials"
+)
+
+// Validators that catch malformed keys and content at plan time, instead of failing with a cryptic error on read or
+// apply. Unknown and null values are left to the other checks.
+
+// Ensure the validators fully satisfy framework interfaces.
+var _ validator.String = masterKeyValidator{}
+var _ validator.String = encryptedContentValidator{}
+var _ validator.String = yamlContentValidator{}
+
+// masterKeyValidator checks that a master key has the format Rails generates.
+type masterKeyValidator struct{}
+
+func (v masterKeyValidator) Description(ctx context.Context) string {
+	return v.MarkdownDescription(ctx)
+}
+
+func (v masterKeyValidator) MarkdownDescription(ctx context.Context) string {
+	return fmt.Sprintf("value must be %d hex characters", credentials.MasterKeyLengthBytes*2)
+}
+
+func (v masterKeyValidator) ValidateString(ctx context.Context, req validator.StringRequest, resp *validator.StringResponse) {
+	if req.ConfigValue.IsNull() || req.ConfigValue.IsUnknown() {
+		return
+	}
+
+	err := credentials.ValidateMasterKey(credentials.SanitizeMasterKey(req.ConfigValue.ValueString()))
+	if err != nil {
+		resp.Diagnostics.AddAttributeError(req.Path, "Invalid master key", fmt.Sprintf("The master key is not valid: %s.", err))
+	}
+}
+
+// encryptedContentValidator checks that encrypted content has the parts of a Rails encrypted file, without
+// decrypting it.
+type encryptedContentValidator struct{}
+
+func (v encryptedContentValidator) Description(ctx context.Context) string {
+	return v.MarkdownDescription(ctx)
+}
+
+func (v encryptedContentValidator) MarkdownDescription(ctx context.Context) string {
+	return "value must be the content of a Rails encrypted file: the ciphertext, IV and tag, base64 encoded and separated by `--`"
+}
+
+func (v encryptedContentValidator) ValidateString(ctx context.Context, req validator.StringRequest, resp *validator.StringResponse) {
+	if req.ConfigValue.IsNull() || req.ConfigValue.IsUnknown() {
+		return
+	}
+
+	_, err := credentials.ParseEnvelope(req.ConfigValue.ValueString())
+	if err != nil {
+		resp.Diagnostics.AddAttributeError(req.Path, "Invalid encrypted credentials", fmt.Sprintf("The value is not the content of a Rails encrypted file: %s.", err))
+	}
+}
+
+// yamlContentValidator checks that plaintext credentials are a YAML mapping, unless the `validate_yaml` attribute next
+// to the validated one is false, e.g. for a file that is not YAML on purpose.
+type yamlContentValidator struct{}
+
+func (v yamlContentValidator) Description(ctx context.Context) string {
+	return v.MarkdownDescription(ctx)
+}
+
+func (v yamlContentValidator) MarkdownDescription(ctx context.Context) string {
+	return "value must be a YAML mapping, unless `validate_yaml` is false"
+}
+
+func (v yamlContentValidator) ValidateString(ctx context.Context, req validator.StringRequest, resp *validator.StringResponse) {
+	if req.ConfigValue.IsNull() || req.ConfigValue.IsUnknown() {
+		return
+	}
+
+	var enabled types.Bool
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, req.Path.ParentPath().AtName("validate_yaml"), &enabled)...)
+	if resp.Diagnostics.HasError() || enabled.IsUnknown() || (!enabled.IsNull() && !enabled.ValueBool()) {
+		return
+	}
+
+	_, err := credentials.ParseContent(req.ConfigValue.ValueString())
+	if err == nil {
+		return
+	}
+	var yamlErr *credentials.YAMLError
+	if errors.As(err, &yamlErr) && yamlErr.Line > 0 {
+		resp.Diagnostics.AddAttributeError(req.Path, "Invalid YAML", fmt.Sprintf("The credentials are not valid YAML at line %d: %s. Set validate_yaml = false to skip this check.", yamlErr.Line, yamlErr.Message))
+		return
+	}
+	resp.Diagnostics.AddAttributeError(req.Path, "Invalid YAML", fmt.Sprintf("The credentials are not valid: %s. Set validate_yaml = false to skip this check.", err))
+}
+
+// validateYAMLDescription is the description of the `validate_yaml` attribute yamlContentValidator reads.
+const validateYAMLDescription = "Whether to check that the content is a valid YAML mapping at plan time, as Rails expects. Defaults to `true`"
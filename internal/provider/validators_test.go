@@ -0,0 +1,51 @@
+package provider
+
+import (
+	"context"
+	"github.com/hashicorp/terraform-plugin-framework/datasource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestYAMLContentValidator(t *testing.T) {
+	s := schema.Schema{
+		Attributes: map[string]schema.Attribute{
+			"content":       schema.StringAttribute{Optional: true},
+			"validate_yaml": schema.BoolAttribute{Optional: true},
+		},
+	}
+	validate := func(content string, validateYAML *bool) validator.StringResponse {
+		typ := s.Type().TerraformType(context.Background())
+		var v any
+		if validateYAML != nil {
+			v = *validateYAML
+		}
+		config := tfsdk.Config{Schema: s, Raw: tftypes.NewValue(typ, map[string]tftypes.Value{
+			"content":       tftypes.NewValue(tftypes.String, content),
+			"validate_yaml": tftypes.NewValue(tftypes.Bool, v),
+		})}
+		resp := validator.StringResponse{}
+		yamlContentValidator{}.ValidateString(context.Background(), validator.StringRequest{
+			Path:        path.Root("content"),
+			ConfigValue: types.StringValue(content),
+			Config:      config,
+		}, &resp)
+		return resp
+	}
+
+	assert.False(t, validate("a: 1\n", nil).Diagnostics.HasError())
+
+	resp := validate("a: 1\n  b: [\n", nil)
+	assert.True(t, resp.Diagnostics.HasError())
+	// the parser does not tell the column, so none is reported
+	assert.Regexp(t, `^The credentials are not valid YAML at line \d+: `, resp.Diagnostics[0].Detail())
+	assert.NotContains(t, resp.Diagnostics[0].Detail(), "column")
+
+	disabled := false
+	assert.False(t, validate("a: 1\n  b: [\n", &disabled).Diagnostics.HasError())
+}
@@ -0,0 +1,104 @@
+package provider
+
+import (
+	"fmt"
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/jamesits/go-rails-credentials/pkg/credentials"
+	"math"
+	"math/big"
+	"sort"
+)
+
+// treeValue converts the parsed credentials into a Terraform value: mappings become objects, sequences become tuples,
+// and scalars keep their YAML type. Mappings and sequences can mix types, which maps and lists can not. Timestamps,
+// and the floats Terraform numbers can not hold (.nan and .inf), become strings.
+func treeValue(node any) (attr.Value, diag.Diagnostics) {
+	var diags diag.Diagnostics
+
+	switch n := node.(type) {
+	case map[string]any:
+		keys := make([]string, 0, len(n))
+		for k := range n {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		attrTypes := make(map[string]attr.Type, len(n))
+		attrs := make(map[string]attr.Value, len(n))
+		for _, k := range keys {
+			v, d := treeValue(n[k])
+			diags.Append(d...)
+			if diags.HasError() {
+				return nil, diags
+			}
+			attrTypes[k] = v.Type(nil)
+			attrs[k] = v
+		}
+		v, d := types.ObjectValue(attrTypes, attrs)
+		diags.Append(d...)
+		return v, diags
+	case []any:
+		elemTypes := make([]attr.Type, len(n))
+		elems := make([]attr.Value, len(n))
+		for i, e := range n {
+			v, d := treeValue(e)
+			diags.Append(d...)
+			if diags.HasError() {
+				return nil, diags
+			}
+			elemTypes[i] = v.Type(nil)
+			elems[i] = v
+		}
+		v, d := types.TupleValue(elemTypes, elems)
+		diags.Append(d...)
+		return v, diags
+	case nil:
+		return types.StringNull(), diags
+	case string:
+		return types.StringValue(n), diags
+	case bool:
+		return types.BoolValue(n), diags
+	case int:
+		return types.NumberValue(new(big.Float).SetInt64(int64(n))), diags
+	case int64:
+		return types.NumberValue(new(big.Float).SetInt64(n)), diags
+	case uint64:
+		return types.NumberValue(new(big.Float).SetUint64(n)), diags
+	case float64:
+		// .nan and .inf are valid YAML, but not Terraform numbers
+		if math.IsNaN(n) || math.IsInf(n, 0) {
+			return types.StringValue(credentials.FormatScalar(n)), diags
+		}
+		return types.NumberValue(big.NewFloat(n)), diags
+	default:
+		return types.StringValue(credentials.FormatScalar(n)), diags
+	}
+}
+
+// flatValue converts the parsed credentials into a map of strings by their dotted key path, as Rails would see the
+// values after interpolation.
+func flatValue(tree any) (types.Map, diag.Diagnostics) {
+	elems := map[string]attr.Value{}
+	for _, l := range credentials.Leaves(tree) {
+		elems[l.Key()] = types.StringValue(credentials.FormatScalar(l.Value))
+	}
+	return types.MapValue(types.StringType, elems)
+}
+
+// contentValues parses plaintext credentials into a dynamic value for a `values` attribute.
+func contentValues(content string) (types.Dynamic, diag.Diagnostics) {
+	var diags diag.Diagnostics
+
+	tree, err := credentials.ParseContent(content)
+	if err != nil {
+		diags.AddError("Credentials parse failed", fmt.Sprintf("The decrypted credentials are not a valid YAML mapping: %s", err))
+		return types.DynamicNull(), diags
+	}
+	v, d := treeValue(tree)
+	diags.Append(d...)
+	if diags.HasError() {
+		return types.DynamicNull(), diags
+	}
+	return types.DynamicValue(v), diags
+}
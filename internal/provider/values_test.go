@@ -0,0 +1,29 @@
+package provider
+
+import (
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/stretchr/testify/assert"
+	"math/big"
+	"testing"
+)
+
+func TestContentValues(t *testing.T) {
+	v, diags := contentValues("a: 1\nb: [x, true]\nc:\n  d: 1.5\n")
+	assert.False(t, diags.HasError())
+	obj := v.UnderlyingValue().(types.Object).Attributes()
+	assert.True(t, types.NumberValue(big.NewFloat(1)).Equal(obj["a"]))
+	assert.Equal(t, []attr.Value{types.StringValue("x"), types.BoolValue(true)}, obj["b"].(types.Tuple).Elements())
+	assert.True(t, types.NumberValue(big.NewFloat(1.5)).Equal(obj["c"].(types.Object).Attributes()["d"]))
+
+	// not representable as Terraform numbers
+	v, diags = contentValues("nan: .nan\ninf: .inf\nneg: -.inf\n")
+	assert.False(t, diags.HasError())
+	obj = v.UnderlyingValue().(types.Object).Attributes()
+	assert.Equal(t, types.StringValue("NaN"), obj["nan"])
+	assert.Equal(t, types.StringValue("+Inf"), obj["inf"])
+	assert.Equal(t, types.StringValue("-Inf"), obj["neg"])
+
+	_, diags = contentValues("- not a mapping\n")
+	assert.True(t, diags.HasError())
+}
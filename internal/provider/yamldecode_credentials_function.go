@@ -0,0 +1,57 @@
+package provider
+
+import (
+	"context"
+	"github.com/hashicorp/terraform-plugin-framework/function"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/jamesits/go-rails-credentials/pkg/credentials"
+)
+
+// Ensure provider defined types fully satisfy framework interfaces.
+var _ function.Function = &YAMLDecodeCredentialsFunction{}
+
+func NewYAMLDecodeCredentialsFunction() function.Function {
+	return &YAMLDecodeCredentialsFunction{}
+}
+
+// YAMLDecodeCredentialsFunction parses plaintext credentials into an object, the same way the `values` attributes do.
+type YAMLDecodeCredentialsFunction struct{}
+
+func (f *YAMLDecodeCredentialsFunction) Metadata(ctx context.Context, req function.MetadataRequest, resp *function.MetadataResponse) {
+	resp.Name = "yamldecode_credentials"
+}
+
+func (f *YAMLDecodeCredentialsFunction) Definition(ctx context.Context, req function.DefinitionRequest, resp *function.DefinitionResponse) {
+	resp.Definition = function.Definition{
+		Summary:             "Parses plaintext Rails credentials",
+		MarkdownDescription: "Parses plaintext Rails credentials, e.g. the result of `decrypt`, into an object. Unlike `yamldecode`, the top level must be a mapping, keys are always strings, and timestamps are returned as RFC 3339 strings.",
+		Parameters: []function.Parameter{
+			function.StringParameter{
+				Name:                "content",
+				MarkdownDescription: "Raw credentials in YAML format",
+			},
+		},
+		Return: function.DynamicReturn{},
+	}
+}
+
+func (f *YAMLDecodeCredentialsFunction) Run(ctx context.Context, req function.RunRequest, resp *function.RunResponse) {
+	var content string
+
+	resp.Error = req.Arguments.Get(ctx, &content)
+	if resp.Error != nil {
+		return
+	}
+
+	tree, err := credentials.ParseContent(content)
+	if err != nil {
+		resp.Error = function.NewArgumentFuncError(0, "invalid credentials: "+err.Error())
+		return
+	}
+	v, diags := treeValue(tree)
+	if diags.HasError() {
+		resp.Error = function.FuncErrorFromDiags(ctx, diags)
+		return
+	}
+	resp.Error = resp.Result.Set(ctx, types.DynamicValue(v))
+}
@@ -0,0 +1,70 @@
+package credentials
+
+import (
+	"reflect"
+	"sort"
+)
+
+// ChangeKind is how a value differs between two versions of the credentials.
+type ChangeKind string
+
+const (
+	Added    ChangeKind = "added"
+	Removed  ChangeKind = "removed"
+	Modified ChangeKind = "modified"
+)
+
+// Symbol returns "+", "-" or "~", to list changes without their values.
+func (k ChangeKind) Symbol() string {
+	switch k {
+	case Added:
+		return "+"
+	case Removed:
+		return "-"
+	default:
+		return "~"
+	}
+}
+
+// Change is a difference between two versions of the credentials at one leaf. Old is nil for added values and New is
+// nil for removed values.
+type Change struct {
+	Path []string
+	Kind ChangeKind
+	Old  any
+	New  any
+}
+
+// Key returns the path joined with PathSeparator.
+func (c Change) Key() string {
+	return Leaf{Path: c.Path}.Key()
+}
+
+// Diff compares the leaves of two credentials trees, and returns the changes sorted by path.
+// A value that changes type counts as modified; a mapping replaced by a scalar shows up as its leaves being removed
+// and the scalar being added.
+func Diff(from any, to any) []Change {
+	oldLeaves := map[string]Leaf{}
+	for _, l := range Leaves(from) {
+		oldLeaves[l.Key()] = l
+	}
+
+	var ret []Change
+	for _, l := range Leaves(to) {
+		o, ok := oldLeaves[l.Key()]
+		if !ok {
+			ret = append(ret, Change{Path: l.Path, Kind: Added, New: l.Value})
+			continue
+		}
+		delete(oldLeaves, l.Key())
+		if !reflect.DeepEqual(o.Value, l.Value) {
+			ret = append(ret, Change{Path: l.Path, Kind: Modified, Old: o.Value, New: l.Value})
+		}
+	}
+	for _, o := range oldLeaves {
+		ret = append(ret, Change{Path: o.Path, Kind: Removed, Old: o.Value})
+	}
+
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Key() < ret[j].Key() })
+	return ret
+}
@@ -0,0 +1,43 @@
+package credentials
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	from, err := ParseContent(`
+aws:
+  access_key_id: 123
+  region: us-east-1
+hosts: [a, b]
+removed: true
+`)
+	assert.NoError(t, err)
+	to, err := ParseContent(`
+aws:
+  access_key_id: "123"
+  region: us-east-1
+hosts: [a, c, d]
+added: 1
+`)
+	assert.NoError(t, err)
+
+	var summary []string
+	for _, c := range Diff(from, to) {
+		summary = append(summary, string(c.Kind)+" "+c.Key())
+	}
+	assert.Equal(t, []string{
+		"added added",
+		"modified aws.access_key_id",
+		"modified hosts.1",
+		"added hosts.2",
+		"removed removed",
+	}, summary)
+
+	assert.Empty(t, Diff(from, from))
+
+	assert.Equal(t, "+", Added.Symbol())
+	assert.Equal(t, "~", Modified.Symbol())
+	assert.Equal(t, "-", Removed.Symbol())
+}
@@ -59,3 +59,107 @@ func TestLoop(t *testing.T) {
 		assert.Equal(t, p.PlainTextData, des)
 	}
 }
+
+func TestUnmarshalEncodedString(t *testing.T) {
+	// Marshal.dump("abc") with the UTF-8 encoding instance variable
+	des, err := UnmarshalSingleString([]byte("\x04\x08I\"\x08abc\x06:\x06ET"))
+	assert.NoError(t, err)
+	assert.Equal(t, "abc", des)
+
+	_, err = UnmarshalSingleString([]byte("\x04\x08\"\x04"))
+	assert.Error(t, err)
+	_, err = UnmarshalSingleString([]byte("\x04\x08"))
+	assert.Error(t, err)
+}
+
+func TestParseEnvelope(t *testing.T) {
+	for _, p := range testCredPairs {
+		e, err := ParseEnvelope(p.EncryptedData)
+		assert.NoError(t, err)
+		assert.Len(t, e.IV, GcmStandardNonceSize)
+		assert.Len(t, e.Tag, GcmTagSize)
+	}
+
+	for _, s := range []string{
+		"",
+		"YWJj--YWJj",
+		"YWJj--YWJj--YWJjYWJjYWJjYWJjYWJjYQ==",
+		"YWJj--YWJjYWJjYWJjYWJj--YWJj",
+		"!!!--YWJjYWJjYWJjYWJj--YWJjYWJjYWJjYWJjYWJjYQ==",
+	} {
+		_, err := ParseEnvelope(s)
+		assert.Error(t, err, s)
+
+		// must not panic on a malformed envelope
+		_, err = Decrypt(testCredPairs[0].MasterKey, s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestValidateMasterKey(t *testing.T) {
+	for _, p := range testCredPairs {
+		assert.NoError(t, ValidateMasterKey(p.MasterKey))
+	}
+	assert.Error(t, ValidateMasterKey(""))
+	assert.Error(t, ValidateMasterKey("a2683380db86af7597f33561b5f1175"))
+	assert.Error(t, ValidateMasterKey("z2683380db86af7597f33561b5f11755"))
+}
+
+func TestEncryptDeterministic(t *testing.T) {
+	for _, p := range testCredPairs {
+		ser, err := MarshalSingleString(p.PlainTextData)
+		assert.NoError(t, err)
+
+		enc, err := EncryptDeterministic(p.MasterKey, ser)
+		assert.NoError(t, err)
+		again, err := EncryptDeterministic(p.MasterKey, ser)
+		assert.NoError(t, err)
+		assert.Equal(t, enc, again)
+
+		dec, err := Decrypt(p.MasterKey, enc)
+		assert.NoError(t, err)
+		assert.Equal(t, ser, dec)
+
+		mk, err := RandomMasterKey()
+		assert.NoError(t, err)
+		other, err := EncryptDeterministic(mk, ser)
+		assert.NoError(t, err)
+		assert.NotEqual(t, enc, other)
+
+		changed, err := EncryptDeterministic(p.MasterKey, append(ser, ' '))
+		assert.NoError(t, err)
+		e1, err := ParseEnvelope(enc)
+		assert.NoError(t, err)
+		e2, err := ParseEnvelope(changed)
+		assert.NoError(t, err)
+		assert.NotEqual(t, e1.IV, e2.IV)
+	}
+}
+
+func TestKeyFingerprint(t *testing.T) {
+	for _, p := range testCredPairs {
+		f := KeyFingerprint(p.MasterKey)
+		assert.Len(t, f, 16)
+		assert.Equal(t, f, KeyFingerprint(p.MasterKey))
+	}
+	assert.NotEqual(t, KeyFingerprint("a2683380db86af7597f33561b5f11755"), KeyFingerprint("a2683380db86af7597f33561b5f11756"))
+}
+
+func TestRandomSecrets(t *testing.T) {
+	secretKeyBase, err := RandomSecretKeyBase()
+	assert.NoError(t, err)
+	assert.Regexp(t, "^[0-9a-f]{128}$", secretKeyBase)
+
+	keys, err := RandomActiveRecordEncryptionKeys()
+	assert.NoError(t, err)
+	for _, k := range []string{keys.PrimaryKey, keys.DeterministicKey, keys.KeyDerivationSalt} {
+		assert.Regexp(t, "^[0-9A-Za-z]{32}$", k)
+	}
+	assert.NotEqual(t, keys.PrimaryKey, keys.DeterministicKey)
+
+	content, err := NewCredentialsFileContent()
+	assert.NoError(t, err)
+	tree, err := ParseContent(content)
+	assert.NoError(t, err)
+	assert.Regexp(t, "^[0-9a-f]{128}$", tree["secret_key_base"])
+}
@@ -3,6 +3,8 @@ package credentials
 import (
 	"crypto/aes"
 	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
 	"encoding/base64"
 	"encoding/hex"
 	"fmt"
@@ -25,17 +27,17 @@ const (
 
 var Base64Encoding = base64.StdEncoding
 
-// Decrypt decrypts the encrypted file content using the master key.
-// The master key should be a hex-encoded string of 32 hex characters (16 bytes).
+// Envelope is the parsed form of an encrypted file.
+type Envelope struct {
+	CipherText []byte
+	IV         []byte
+	Tag        []byte
+}
+
+// ParseEnvelope splits the encrypted file content into its parts and checks their lengths, without decrypting.
 // The encrypted file content is expected to be in the format:
 // <base64-encoded-content><Separator><base64-encoded-iv><Separator><base64-encoded-tag>
-// The content is encrypted using AES-128-GCM.
-func Decrypt(MasterKey string, EncryptedFileContent string) (DecryptedFileContent []byte, err error) {
-	key, err := hex.DecodeString(MasterKey)
-	if err != nil {
-		return nil, fmt.Errorf("decode master key failed: %w", err)
-	}
-
+func ParseEnvelope(EncryptedFileContent string) (*Envelope, error) {
 	content := strings.SplitN(EncryptedFileContent, Separator, 3)
 	if len(content) != 3 {
 		return nil, fmt.Errorf("parse encrypted file failed")
@@ -48,10 +50,34 @@ func Decrypt(MasterKey string, EncryptedFileContent string) (DecryptedFileConten
 	if err != nil {
 		return nil, fmt.Errorf("parse IV failed: %w", err)
 	}
+	if len(iv) != GcmStandardNonceSize {
+		return nil, fmt.Errorf("parse IV failed: expected %d bytes, got %d", GcmStandardNonceSize, len(iv))
+	}
 	tag, err := Base64Encoding.DecodeString(content[2])
 	if err != nil {
 		return nil, fmt.Errorf("parse tag failed: %w", err)
 	}
+	if len(tag) != GcmTagSize {
+		return nil, fmt.Errorf("parse tag failed: expected %d bytes, got %d", GcmTagSize, len(tag))
+	}
+
+	return &Envelope{CipherText: cipherText, IV: iv, Tag: tag}, nil
+}
+
+// Decrypt decrypts the encrypted file content using the master key.
+// The master key should be a hex-encoded string of 32 hex characters (16 bytes).
+// The encrypted file content is expected to be in the format described in ParseEnvelope.
+// The content is encrypted using AES-128-GCM.
+func Decrypt(MasterKey string, EncryptedFileContent string) (DecryptedFileContent []byte, err error) {
+	key, err := hex.DecodeString(MasterKey)
+	if err != nil {
+		return nil, fmt.Errorf("decode master key failed: %w", err)
+	}
+
+	envelope, err := ParseEnvelope(EncryptedFileContent)
+	if err != nil {
+		return nil, err
+	}
 
 	block, err := aes.NewCipher(key)
 	if err != nil {
@@ -62,7 +88,7 @@ func Decrypt(MasterKey string, EncryptedFileContent string) (DecryptedFileConten
 		return nil, fmt.Errorf("initialize GCM parser failed: %w", err)
 	}
 
-	decryptedFileContent, err := gcm.Open(nil, iv, []byte(string(cipherText)+string(tag)), nil)
+	decryptedFileContent, err := gcm.Open(nil, envelope.IV, []byte(string(envelope.CipherText)+string(envelope.Tag)), nil)
 
 	if err != nil {
 		return decryptedFileContent, fmt.Errorf("decrypt failed: %w", err)
@@ -91,8 +117,45 @@ func Encrypt(MasterKey string, RawFileContent []byte) (EncryptedFileContent stri
 		return "", fmt.Errorf("parser internal error")
 	}
 	iv := encryptedStream[0:GcmStandardNonceSize]
-	content := encryptedStream[GcmStandardNonceSize : len(encryptedStream)-GcmTagSize]
-	tag := encryptedStream[len(encryptedStream)-GcmTagSize:]
+	return formatEnvelope(iv, encryptedStream[GcmStandardNonceSize:]), nil
+}
+
+// EncryptDeterministic encrypts the raw file content like Encrypt, but derives the IV from the master key and the
+// content instead of picking a random one, so the same input always gives the same output. Rails decrypts the result
+// like any other file.
+//
+// This is meant for places that need a stable result, like Terraform functions; the only thing it reveals over
+// Encrypt is whether two files encrypted with the same key have the same content.
+func EncryptDeterministic(MasterKey string, RawFileContent []byte) (EncryptedFileContent string, err error) {
+	key, err := hex.DecodeString(MasterKey)
+	if err != nil {
+		return "", fmt.Errorf("decode master key failed: %w", err)
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return "", fmt.Errorf("initialize AES parser failed: %w", err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", fmt.Errorf("initialize GCM parser failed: %w", err)
+	}
+
+	// a separate key for the IV, so the master key is not used for both AES and HMAC
+	ivKey := hmac.New(sha256.New, key)
+	ivKey.Write([]byte("go-rails-credentials deterministic IV"))
+	mac := hmac.New(sha256.New, ivKey.Sum(nil))
+	mac.Write(RawFileContent)
+	iv := mac.Sum(nil)[:GcmStandardNonceSize]
+
+	return formatEnvelope(iv, gcm.Seal(nil, iv, RawFileContent, nil)), nil
+}
+
+// formatEnvelope joins the IV and the sealed content, which ends with the tag, in the format described in
+// ParseEnvelope.
+func formatEnvelope(iv []byte, sealed []byte) string {
+	content := sealed[:len(sealed)-GcmTagSize]
+	tag := sealed[len(sealed)-GcmTagSize:]
 
 	sb := strings.Builder{}
 	sb.WriteString(Base64Encoding.EncodeToString(content))
@@ -100,5 +163,5 @@ func Encrypt(MasterKey string, RawFileContent []byte) (EncryptedFileContent stri
 	sb.WriteString(Base64Encoding.EncodeToString(iv))
 	sb.WriteString(Separator)
 	sb.WriteString(Base64Encoding.EncodeToString(tag))
-	return sb.String(), nil
+	return sb.String()
 }
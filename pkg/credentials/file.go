@@ -0,0 +1,128 @@
+package credentials
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// maxSymlinks is the number of symbolic links WriteFile follows before giving up, like ELOOP on Linux.
+const maxSymlinks = 40
+
+// WriteFile atomically replaces the content of a file, so readers see either the old or the new content, and the new
+// content survives a crash once WriteFile returns.
+//
+// If path is a symbolic link, the file it points to is replaced and the link is kept. The mode, owner and group of an
+// existing file are kept (the owner and group where permitted); a new file is created with perm, minus the umask,
+// along with its parent directories.
+func WriteFile(path string, content []byte, perm os.FileMode) error {
+	target, err := resolveSymlinks(path)
+	if err != nil {
+		return err
+	}
+	dir := filepath.Dir(target)
+
+	existing, err := os.Stat(target)
+	if errors.Is(err, os.ErrNotExist) {
+		existing = nil
+		err = os.MkdirAll(dir, 0o777)
+		if err != nil {
+			return fmt.Errorf("unable to create directory: %w", err)
+		}
+	} else if err != nil {
+		return err
+	}
+
+	// the temporary file must be in the same directory, since rename is only atomic within a file system
+	temp, f, err := createUniqueFile(dir, "."+filepath.Base(target)+".", ".tmp", perm)
+	if err != nil {
+		return fmt.Errorf("unable to create temporary file: %w", err)
+	}
+	defer os.Remove(temp)
+
+	_, err = f.Write(content)
+	if err == nil && existing != nil {
+		err = f.Chmod(existing.Mode().Perm())
+		if err == nil {
+			keepOwner(f, existing)
+		}
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("unable to write temporary file: %w", err)
+	}
+
+	err = os.Rename(temp, target)
+	if err != nil {
+		return fmt.Errorf("unable to overwrite destination file: %w", err)
+	}
+
+	// the rename itself is only durable once the directory is synced
+	err = syncDir(dir)
+	if err != nil {
+		return fmt.Errorf("unable to sync directory: %w", err)
+	}
+	return nil
+}
+
+// WriteEncryptedFile encrypts the plaintext credentials with the master key and writes them to path with WriteFile.
+func WriteEncryptedFile(path string, masterKey string, content string) error {
+	object, err := MarshalSingleString(content)
+	if err != nil {
+		return fmt.Errorf("unable to marshal object: %w", err)
+	}
+	encrypted, err := Encrypt(masterKey, object)
+	if err != nil {
+		return fmt.Errorf("unable to encrypt: %w", err)
+	}
+	return WriteFile(path, []byte(encrypted), 0o666)
+}
+
+// resolveSymlinks follows symbolic links until it reaches a path that is not one. Unlike filepath.EvalSymlinks,
+// the final target does not have to exist.
+func resolveSymlinks(path string) (string, error) {
+	for i := 0; i < maxSymlinks; i++ {
+		info, err := os.Lstat(path)
+		if errors.Is(err, os.ErrNotExist) || (err == nil && info.Mode()&os.ModeSymlink == 0) {
+			return path, nil
+		} else if err != nil {
+			return "", err
+		}
+
+		link, err := os.Readlink(path)
+		if err != nil {
+			return "", err
+		}
+		if !filepath.IsAbs(link) {
+			link = filepath.Join(filepath.Dir(path), link)
+		}
+		path = link
+	}
+	return "", fmt.Errorf("too many levels of symbolic links: %s", path)
+}
+
+// createUniqueFile creates a new file with a random name. Unlike os.CreateTemp, it takes the permissions of the file,
+// which are then subject to the umask like those of any new file.
+func createUniqueFile(dir string, prefix string, suffix string, perm os.FileMode) (string, *os.File, error) {
+	random := make([]byte, 8)
+	for {
+		_, err := rand.Read(random)
+		if err != nil {
+			return "", nil, err
+		}
+		name := filepath.Join(dir, prefix+hex.EncodeToString(random)+suffix)
+		f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+		if errors.Is(err, os.ErrExist) {
+			continue
+		}
+		return name, f, err
+	}
+}
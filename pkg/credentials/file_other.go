@@ -0,0 +1,15 @@
+//go:build !unix
+
+package credentials
+
+import (
+	"os"
+)
+
+// keepOwner does nothing, since ownership is part of the ACL, which the new file inherits from the directory.
+func keepOwner(f *os.File, existing os.FileInfo) {}
+
+// syncDir does nothing, since directories can not be opened for syncing, and renames are journaled by NTFS.
+func syncDir(dir string) error {
+	return nil
+}
@@ -0,0 +1,62 @@
+package credentials
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestWriteFile(t *testing.T) {
+	dir := t.TempDir()
+
+	// new file, with its parent directory
+	path := filepath.Join(dir, "config", "credentials.yml.enc")
+	assert.NoError(t, WriteFile(path, []byte("first"), 0o600))
+	content, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "first", string(content))
+
+	// the mode of an existing file is kept
+	assert.NoError(t, os.Chmod(path, 0o640))
+	assert.NoError(t, WriteFile(path, []byte("second"), 0o600))
+	info, err := os.Stat(path)
+	assert.NoError(t, err)
+	if runtime.GOOS != "windows" {
+		assert.Equal(t, os.FileMode(0o640), info.Mode().Perm())
+	}
+
+	// no temporary file is left behind
+	entries, err := os.ReadDir(filepath.Dir(path))
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
+
+func TestWriteFileSymlink(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("creating symbolic links needs extra privileges on Windows")
+	}
+	dir := t.TempDir()
+
+	target := filepath.Join(dir, "shared", "credentials.yml.enc")
+	assert.NoError(t, WriteFile(target, []byte("first"), 0o666))
+	link := filepath.Join(dir, "credentials.yml.enc")
+	assert.NoError(t, os.Symlink(filepath.Join("shared", "credentials.yml.enc"), link))
+
+	assert.NoError(t, WriteFile(link, []byte("second"), 0o666))
+	info, err := os.Lstat(link)
+	assert.NoError(t, err)
+	assert.NotZero(t, info.Mode()&os.ModeSymlink)
+	content, err := os.ReadFile(target)
+	assert.NoError(t, err)
+	assert.Equal(t, "second", string(content))
+
+	// a dangling link is followed to create its target
+	dangling := filepath.Join(dir, "master.key")
+	assert.NoError(t, os.Symlink(filepath.Join(dir, "keys", "master.key"), dangling))
+	assert.NoError(t, WriteFile(dangling, []byte("key"), 0o600))
+	content, err = os.ReadFile(filepath.Join(dir, "keys", "master.key"))
+	assert.NoError(t, err)
+	assert.Equal(t, "key", string(content))
+}
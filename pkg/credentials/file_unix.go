@@ -0,0 +1,29 @@
+//go:build unix
+
+package credentials
+
+import (
+	"os"
+	"syscall"
+)
+
+// keepOwner gives the file the owner and group of the existing file. This only fully works as root; other users can
+// usually still keep the group if they are a member of it, so failures are ignored.
+func keepOwner(f *os.File, existing os.FileInfo) {
+	st, ok := existing.Sys().(*syscall.Stat_t)
+	if !ok {
+		return
+	}
+	if f.Chown(int(st.Uid), int(st.Gid)) != nil {
+		_ = f.Chown(-1, int(st.Gid))
+	}
+}
+
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
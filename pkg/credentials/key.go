@@ -2,8 +2,10 @@ package credentials
 
 import (
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
+	"math/big"
 	"strings"
 )
 
@@ -11,8 +13,25 @@ const (
 	// MasterKeyLengthBytes is the length of the master key in bytes.
 	// AES-128-GCM requires a 16-byte key.
 	MasterKeyLengthBytes = 16
+
+	// SecretKeyBaseLengthBytes is the length of secret_key_base in bytes, before hex encoding.
+	// https://github.com/rails/rails/blob/04df9bc3d120b51447bde54caa56e9237cb8da0e/railties/lib/rails/generators/rails/credentials/credentials_generator.rb#L42
+	SecretKeyBaseLengthBytes = 64
+
+	// ActiveRecordEncryptionKeyLength is the length of each of the keys `bin/rails db:encryption:init` generates.
+	// https://github.com/rails/rails/blob/04df9bc3d120b51447bde54caa56e9237cb8da0e/activerecord/lib/active_record/railties/databases.rake
+	ActiveRecordEncryptionKeyLength = 32
+
+	alphanumeric = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"
 )
 
+// ActiveRecordEncryptionKeys are the keys under `active_record_encryption` in the credentials.
+type ActiveRecordEncryptionKeys struct {
+	PrimaryKey        string `yaml:"primary_key"`
+	DeterministicKey  string `yaml:"deterministic_key"`
+	KeyDerivationSalt string `yaml:"key_derivation_salt"`
+}
+
 // RandomMasterKey generates a random master key.
 func RandomMasterKey() (string, error) {
 	key := make([]byte, MasterKeyLengthBytes)
@@ -23,6 +42,62 @@ func RandomMasterKey() (string, error) {
 	return hex.EncodeToString(key), nil
 }
 
+// RandomSecretKeyBase generates a secret_key_base like `bin/rails secret` does.
+func RandomSecretKeyBase() (string, error) {
+	r := make([]byte, SecretKeyBaseLengthBytes)
+	_, err := rand.Read(r)
+	if err != nil {
+		return "", fmt.Errorf("unable to generate randomness: %w", err)
+	}
+	return hex.EncodeToString(r), nil
+}
+
+// RandomActiveRecordEncryptionKeys generates the keys like `bin/rails db:encryption:init` does.
+func RandomActiveRecordEncryptionKeys() (ActiveRecordEncryptionKeys, error) {
+	var keys ActiveRecordEncryptionKeys
+	for _, k := range []*string{&keys.PrimaryKey, &keys.DeterministicKey, &keys.KeyDerivationSalt} {
+		s, err := randomAlphanumeric(ActiveRecordEncryptionKeyLength)
+		if err != nil {
+			return ActiveRecordEncryptionKeys{}, err
+		}
+		*k = s
+	}
+	return keys, nil
+}
+
+// randomAlphanumeric works like Ruby's SecureRandom.alphanumeric.
+func randomAlphanumeric(n int) (string, error) {
+	ret := make([]byte, n)
+	limit := big.NewInt(int64(len(alphanumeric)))
+	for i := range ret {
+		c, err := rand.Int(rand.Reader, limit)
+		if err != nil {
+			return "", fmt.Errorf("unable to generate randomness: %w", err)
+		}
+		ret[i] = alphanumeric[c.Int64()]
+	}
+	return string(ret), nil
+}
+
+// ValidateMasterKey checks that a master key, after SanitizeMasterKey, has the format Rails generates.
+func ValidateMasterKey(masterKey string) error {
+	if len(masterKey) != MasterKeyLengthBytes*2 {
+		return fmt.Errorf("master key must be %d hex characters, got %d characters", MasterKeyLengthBytes*2, len(masterKey))
+	}
+	_, err := hex.DecodeString(masterKey)
+	if err != nil {
+		return fmt.Errorf("master key must be hex encoded: %w", err)
+	}
+	return nil
+}
+
 func SanitizeMasterKey(in string) string {
 	return strings.Trim(in, "\r\n")
 }
+
+// KeyFingerprint returns a short identifier of a master key that is safe to show, e.g. to tell which key a file is
+// encrypted with. It is the start of the SHA-256 digest of the key, which does not reveal the key itself.
+func KeyFingerprint(masterKey string) string {
+	digest := sha256.Sum256([]byte(masterKey))
+	return hex.EncodeToString(digest[:8])
+}
@@ -13,11 +13,24 @@ import (
 // UnmarshalSingleString extracts a single string from a Ruby marshalled object.
 // The string must be the first item. Everything else is discarded.
 func UnmarshalSingleString(marshalledObject []byte) (string, error) {
+	if len(marshalledObject) < 4 {
+		return "", fmt.Errorf("length validation failed, requires at least 4, has %d", len(marshalledObject))
+	}
+
 	// version
 	if (marshalledObject[0] != 0x04) || (marshalledObject[1] != 0x08) {
 		return "", fmt.Errorf("unknown marshal format %02x%02x", marshalledObject[0], marshalledObject[1])
 	}
 
+	// Strings that are not binary carry their encoding as an instance variable, which wraps the object with an 'I'.
+	// Rails writes this when the content did not come from a binary read, e.g. a freshly generated secrets.yml.enc.
+	if marshalledObject[2] == 0x49 {
+		marshalledObject = append([]byte{marshalledObject[0], marshalledObject[1]}, marshalledObject[3:]...)
+		if len(marshalledObject) < 4 {
+			return "", fmt.Errorf("length validation failed, requires at least 4, has %d", len(marshalledObject))
+		}
+	}
+
 	// type
 	if marshalledObject[2] != 0x22 {
 		return "", fmt.Errorf("unknown object type: %02x", marshalledObject[2])
@@ -26,6 +39,9 @@ func UnmarshalSingleString(marshalledObject []byte) (string, error) {
 	// length
 	var length int
 	var start int
+	if n := int(marshalledObject[3]); n >= 0x01 && n <= 0x04 && len(marshalledObject) < 4+n {
+		return "", fmt.Errorf("length validation failed, requires %d, has %d", 4+n, len(marshalledObject))
+	}
 	switch marshalledObject[3] {
 	case 0x00, 0xfc, 0xfd, 0xfe, 0xff:
 		return "", fmt.Errorf("unsupported object length: %02x", marshalledObject[3])
@@ -0,0 +1,28 @@
+package credentials
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+// Default file locations relative to the root of a Rails project.
+// https://github.com/rails/rails/blob/04df9bc3d120b51447bde54caa56e9237cb8da0e/railties/lib/rails/commands/credentials/credentials_command.rb
+var (
+	ConfigDir               = "config"
+	PerEnvironmentDir       = filepath.Join(ConfigDir, "credentials")
+	SecretsFile             = filepath.Join(ConfigDir, "secrets.yml")
+	EncryptedSecretsFile    = filepath.Join(ConfigDir, "secrets.yml.enc")
+	EncryptedSecretsKeyFile = filepath.Join(ConfigDir, "secrets.yml.key")
+
+	// SchemaFile is not a Rails convention; see Schema.
+	SchemaFile = filepath.Join(ConfigDir, "credentials.schema.json")
+)
+
+// DefaultPaths returns the master key file and the encrypted credentials file Rails uses for an environment.
+// An empty environment refers to the global `config/credentials.yml.enc`.
+func DefaultPaths(environment string) (masterKeyFile string, encryptedCredentialsFile string) {
+	if environment == "" {
+		return filepath.Join(ConfigDir, "master.key"), filepath.Join(ConfigDir, "credentials.yml.enc")
+	}
+	return filepath.Join(PerEnvironmentDir, fmt.Sprintf("%s.key", environment)), filepath.Join(PerEnvironmentDir, fmt.Sprintf("%s.yml.enc", environment))
+}
@@ -0,0 +1,285 @@
+package credentials
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Schema describes the expected structure of the credentials with a subset of JSON Schema: type, properties,
+// required, additionalProperties (boolean only), items, enum, pattern, minLength and maxLength.
+// Per-environment overrides go into the non-standard "environments" keyword and are merged into the base schema by
+// ForEnvironment.
+type Schema struct {
+	Type                 SchemaTypes        `json:"type,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *bool              `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Enum                 []any              `json:"enum,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+
+	Environments map[string]*Schema `json:"environments,omitempty"`
+
+	pattern *regexp.Regexp
+}
+
+// SchemaTypes is the "type" keyword, which is either a single type name or a list of them.
+type SchemaTypes []string
+
+func (t *SchemaTypes) UnmarshalJSON(b []byte) error {
+	var single string
+	if json.Unmarshal(b, &single) == nil {
+		*t = SchemaTypes{single}
+		return nil
+	}
+	var multiple []string
+	err := json.Unmarshal(b, &multiple)
+	if err != nil {
+		return fmt.Errorf("type must be a string or an array of strings")
+	}
+	*t = multiple
+	return nil
+}
+
+// SchemaViolation is a place where the credentials do not match the schema.
+type SchemaViolation struct {
+	Path    string
+	Message string
+}
+
+func (v SchemaViolation) String() string {
+	if v.Path == "" {
+		return v.Message
+	}
+	return v.Path + ": " + v.Message
+}
+
+// ParseSchema parses a JSON schema document.
+func ParseSchema(content []byte) (*Schema, error) {
+	s := &Schema{}
+	err := json.Unmarshal(content, s)
+	if err != nil {
+		return nil, fmt.Errorf("parse schema failed: %w", err)
+	}
+	err = s.compile("")
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *Schema) compile(path string) error {
+	var err error
+	if s.Pattern != "" {
+		s.pattern, err = regexp.Compile(s.Pattern)
+		if err != nil {
+			return fmt.Errorf("invalid pattern at %s: %w", describeSchemaPath(path), err)
+		}
+	}
+	for k, p := range s.Properties {
+		err = p.compile(joinSchemaPath(path, k))
+		if err != nil {
+			return err
+		}
+	}
+	if s.Items != nil {
+		err = s.Items.compile(joinSchemaPath(path, "*"))
+		if err != nil {
+			return err
+		}
+	}
+	for _, e := range s.Environments {
+		err = e.compile(path)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ForEnvironment returns the schema with the overrides for the environment merged in.
+// The overrides may add required keys and properties, or replace any other keyword.
+// An empty environment refers to the global credentials and returns the base schema.
+func (s *Schema) ForEnvironment(environment string) *Schema {
+	o, ok := s.Environments[environment]
+	if environment == "" || !ok {
+		return s
+	}
+	return mergeSchema(s, o)
+}
+
+func mergeSchema(base *Schema, override *Schema) *Schema {
+	if base == nil {
+		return override
+	}
+	if override == nil {
+		return base
+	}
+
+	ret := *base
+	ret.Environments = nil
+	if len(override.Type) > 0 {
+		ret.Type = override.Type
+	}
+	if override.Description != "" {
+		ret.Description = override.Description
+	}
+	if override.AdditionalProperties != nil {
+		ret.AdditionalProperties = override.AdditionalProperties
+	}
+	if override.Items != nil {
+		ret.Items = mergeSchema(base.Items, override.Items)
+	}
+	if override.Enum != nil {
+		ret.Enum = override.Enum
+	}
+	if override.Pattern != "" {
+		ret.Pattern, ret.pattern = override.Pattern, override.pattern
+	}
+	if override.MinLength != nil {
+		ret.MinLength = override.MinLength
+	}
+	if override.MaxLength != nil {
+		ret.MaxLength = override.MaxLength
+	}
+
+	ret.Required = append(append([]string{}, base.Required...), override.Required...)
+	if len(override.Properties) > 0 {
+		ret.Properties = make(map[string]*Schema, len(base.Properties)+len(override.Properties))
+		for k, v := range base.Properties {
+			ret.Properties[k] = v
+		}
+		for k, v := range override.Properties {
+			ret.Properties[k] = mergeSchema(base.Properties[k], v)
+		}
+	}
+	return &ret
+}
+
+// Validate checks a credentials tree against the schema and returns every violation, sorted by path.
+func (s *Schema) Validate(tree any) []SchemaViolation {
+	var ret []SchemaViolation
+	s.validate(tree, "", &ret)
+	sort.SliceStable(ret, func(i, j int) bool { return ret[i].Path < ret[j].Path })
+	return ret
+}
+
+func (s *Schema) validate(value any, path string, ret *[]SchemaViolation) {
+	report := func(format string, args ...any) {
+		*ret = append(*ret, SchemaViolation{Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if len(s.Type) > 0 {
+		actual := schemaTypeOf(value)
+		matched := false
+		for _, t := range s.Type {
+			if t == actual || (t == "number" && actual == "integer") {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			report("expected %s, got %s", strings.Join(s.Type, " or "), actual)
+			return
+		}
+	}
+
+	if len(s.Enum) > 0 {
+		matched := false
+		for _, e := range s.Enum {
+			if schemaTypeOf(e) == schemaTypeOf(value) && FormatScalar(e) == FormatScalar(value) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			report("must be one of the allowed values")
+		}
+	}
+
+	switch v := value.(type) {
+	case map[string]any:
+		for _, k := range s.Required {
+			if _, ok := v[k]; !ok {
+				*ret = append(*ret, SchemaViolation{Path: joinSchemaPath(path, k), Message: "is required"})
+			}
+		}
+		for k, child := range v {
+			if p, ok := s.Properties[k]; ok {
+				p.validate(child, joinSchemaPath(path, k), ret)
+			} else if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+				*ret = append(*ret, SchemaViolation{Path: joinSchemaPath(path, k), Message: "is not allowed"})
+			}
+		}
+
+	case []any:
+		if s.Items != nil {
+			for i, child := range v {
+				s.Items.validate(child, joinSchemaPath(path, fmt.Sprint(i)), ret)
+			}
+		}
+
+	case string:
+		length := utf8.RuneCountInString(v)
+		if s.MinLength != nil && length < *s.MinLength {
+			report("must be at least %d characters long", *s.MinLength)
+		}
+		if s.MaxLength != nil && length > *s.MaxLength {
+			report("must be at most %d characters long", *s.MaxLength)
+		}
+		// values are secrets, so never include them in the message
+		if s.pattern != nil && !s.pattern.MatchString(v) {
+			report("does not match pattern %s", s.Pattern)
+		}
+	}
+}
+
+// schemaTypeOf maps a YAML or JSON value to its JSON Schema type name.
+func schemaTypeOf(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case map[string]any:
+		return "object"
+	case []any:
+		return "array"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case int, int64, uint64:
+		return "integer"
+	case float64:
+		if v == float64(int64(v)) {
+			return "integer"
+		}
+		return "number"
+	case time.Time:
+		// JSON Schema has no type for YAML timestamps; Rails loads them as times, not strings
+		return "timestamp"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
+
+func joinSchemaPath(path string, segment string) string {
+	if path == "" {
+		return segment
+	}
+	return path + PathSeparator + segment
+}
+
+func describeSchemaPath(path string) string {
+	if path == "" {
+		return "the root"
+	}
+	return path
+}
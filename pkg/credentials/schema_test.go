@@ -0,0 +1,73 @@
+package credentials
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+const testSchema = `{
+  "type": "object",
+  "required": ["secret_key_base"],
+  "properties": {
+    "secret_key_base": {"type": "string", "pattern": "^[0-9a-f]+$", "minLength": 128},
+    "aws": {
+      "type": "object",
+      "required": ["access_key_id"],
+      "properties": {
+        "access_key_id": {"type": ["string", "integer"]},
+        "region": {"enum": ["us-east-1", "eu-west-1"]}
+      }
+    },
+    "hosts": {"type": "array", "items": {"type": "string"}}
+  },
+  "environments": {
+    "production": {
+      "required": ["aws"],
+      "properties": {
+        "aws": {"required": ["secret_access_key"]}
+      }
+    }
+  }
+}`
+
+func TestSchemaValidate(t *testing.T) {
+	schema, err := ParseSchema([]byte(testSchema))
+	assert.NoError(t, err)
+
+	for _, p := range testCredPairs {
+		tree, err := ParseContent(p.PlainTextData)
+		assert.NoError(t, err)
+		assert.Empty(t, schema.Validate(tree))
+		assert.Equal(t, []SchemaViolation{{Path: "aws", Message: "is required"}}, schema.ForEnvironment("production").Validate(tree))
+	}
+
+	tree, err := ParseContent(`
+secret_key_base: not-hex
+aws:
+  region: ap-east-1
+hosts: [a, 1, 2024-01-01]
+`)
+	assert.NoError(t, err)
+	assert.Equal(t, []SchemaViolation{
+		{Path: "aws.access_key_id", Message: "is required"},
+		{Path: "aws.region", Message: "must be one of the allowed values"},
+		{Path: "hosts.1", Message: "expected string, got integer"},
+		{Path: "hosts.2", Message: "expected string, got timestamp"},
+		{Path: "secret_key_base", Message: "must be at least 128 characters long"},
+		{Path: "secret_key_base", Message: "does not match pattern ^[0-9a-f]+$"},
+	}, schema.Validate(tree))
+
+	violations := schema.ForEnvironment("production").Validate(tree)
+	assert.Contains(t, violations, SchemaViolation{Path: "aws.secret_access_key", Message: "is required"})
+	assert.Contains(t, violations, SchemaViolation{Path: "aws.access_key_id", Message: "is required"})
+
+	// the base schema is not modified by the merge
+	assert.Len(t, schema.Properties["aws"].Required, 1)
+}
+
+func TestParseSchemaInvalid(t *testing.T) {
+	_, err := ParseSchema([]byte(`{"type": 1}`))
+	assert.Error(t, err)
+	_, err = ParseSchema([]byte(`{"properties": {"a": {"pattern": "("}}}`))
+	assert.Error(t, err)
+}
@@ -0,0 +1,135 @@
+package credentials
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Legacy `config/secrets.yml` support.
+// Rails 5.1 encrypted secrets (`config/secrets.yml.enc`) use the same envelope and serialization as credentials, so
+// they are decrypted with Decrypt and UnmarshalSingleString. What differs is the key file (`config/secrets.yml.key`),
+// the ERB preprocessing and the layout with a shared section and one section per environment.
+// https://github.com/rails/rails/blob/v5.1.0/railties/lib/rails/secrets.rb
+
+const (
+	// SecretsSharedSection is the section of secrets.yml that applies to every environment.
+	SecretsSharedSection = "shared"
+)
+
+var (
+	erbTagPattern = regexp.MustCompile(`(?s)<%(=|#)?(.*?)-?%>`)
+
+	erbEnvIndexPattern = regexp.MustCompile(`^ENV\[\s*(?:"([^"]*)"|'([^']*)')\s*\]$`)
+	erbEnvFetchPattern = regexp.MustCompile(`^ENV\.fetch\(\s*(?:"([^"]*)"|'([^']*)')\s*(?:,\s*(.+?)\s*)?\)$`)
+	erbLiteralPattern  = regexp.MustCompile(`^(?:"([^"]*)"|'([^']*)'|(-?[0-9][0-9_.]*)|(nil))$`)
+)
+
+// ExpandSecretsERB renders the ERB tags commonly found in secrets.yml: `ENV["NAME"]`, `ENV.fetch("NAME")` and
+// `ENV.fetch("NAME", "default")`, optionally followed by `|| "default"`. Variables are resolved with lookup.
+// Names that could not be resolved render as empty strings, like a nil in Ruby, and are returned in unresolved.
+// Any other Ruby code is rejected since we cannot evaluate it.
+func ExpandSecretsERB(content string, lookup func(string) (string, bool)) (expanded string, unresolved []string, err error) {
+	expanded = erbTagPattern.ReplaceAllStringFunc(content, func(tag string) string {
+		if err != nil {
+			return ""
+		}
+		m := erbTagPattern.FindStringSubmatch(tag)
+		switch m[1] {
+		case "#":
+			return ""
+		case "":
+			err = fmt.Errorf("unsupported ERB statement %q", tag)
+			return ""
+		}
+
+		value, name, ok := evalERBExpression(strings.TrimSpace(m[2]), lookup)
+		if name == "" && !ok {
+			err = fmt.Errorf("unsupported ERB expression %q", tag)
+			return ""
+		}
+		if !ok {
+			unresolved = append(unresolved, name)
+		}
+		return value
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	return expanded, unresolved, nil
+}
+
+// evalERBExpression returns the name of the referenced variable, and whether a value was found for it.
+func evalERBExpression(expr string, lookup func(string) (string, bool)) (value string, name string, ok bool) {
+	expr, fallback, hasFallback := strings.Cut(expr, "||")
+	expr = strings.TrimSpace(expr)
+
+	if m := erbEnvIndexPattern.FindStringSubmatch(expr); m != nil {
+		name = m[1] + m[2]
+		value, ok = lookup(name)
+	} else if m := erbEnvFetchPattern.FindStringSubmatch(expr); m != nil {
+		name = m[1] + m[2]
+		value, ok = lookup(name)
+		if !ok && m[3] != "" {
+			value, ok = evalERBLiteral(m[3])
+		}
+	} else {
+		return "", "", false
+	}
+
+	if !ok && hasFallback {
+		value, ok = evalERBLiteral(strings.TrimSpace(fallback))
+	}
+	return value, name, ok
+}
+
+func evalERBLiteral(expr string) (string, bool) {
+	m := erbLiteralPattern.FindStringSubmatch(expr)
+	if m == nil || m[4] != "" {
+		return "", false
+	}
+	return m[1] + m[2] + m[3], true
+}
+
+// ParseSecrets parses a rendered secrets.yml and returns the secrets for an environment.
+// Like Rails, the shared section is merged with the environment section on the top level only.
+func ParseSecrets(content string, environment string) (map[string]any, error) {
+	tree, err := ParseContent(content)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := map[string]any{}
+	for _, section := range []string{SecretsSharedSection, environment} {
+		s, ok := tree[section]
+		if !ok || s == nil {
+			continue
+		}
+		m, ok := s.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("section %s must be a mapping, got %T", section, s)
+		}
+		for k, v := range m {
+			ret[k] = v
+		}
+	}
+	return ret, nil
+}
+
+// SecretsEnvironments lists the environment sections of a rendered secrets.yml, sorted by name.
+func SecretsEnvironments(content string) ([]string, error) {
+	tree, err := ParseContent(content)
+	if err != nil {
+		return nil, err
+	}
+
+	var ret []string
+	for k := range tree {
+		if k != SecretsSharedSection {
+			ret = append(ret, k)
+		}
+	}
+	sort.Strings(ret)
+	return ret, nil
+}
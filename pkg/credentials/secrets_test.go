@@ -0,0 +1,64 @@
+package credentials
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestExpandSecretsERB(t *testing.T) {
+	env := map[string]string{"SECRET_KEY_BASE": "abc"}
+	lookup := func(name string) (string, bool) {
+		v, ok := env[name]
+		return v, ok
+	}
+
+	expanded, unresolved, err := ExpandSecretsERB(`
+<%# legacy secrets %>
+production:
+  secret_key_base: <%= ENV["SECRET_KEY_BASE"] %>
+  api_key: <%= ENV['API_KEY'] %>
+  host: <%= ENV.fetch("HOST", "example.com") %>
+  port: <%= ENV["PORT"] || 3000 %>
+`, lookup)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"API_KEY"}, unresolved)
+	assert.Equal(t, `
+
+production:
+  secret_key_base: abc
+  api_key: 
+  host: example.com
+  port: 3000
+`, expanded)
+
+	_, _, err = ExpandSecretsERB(`key: <%= Rails.root %>`, lookup)
+	assert.Error(t, err)
+	_, _, err = ExpandSecretsERB(`<% if true %>key: value<% end %>`, lookup)
+	assert.Error(t, err)
+}
+
+func TestParseSecrets(t *testing.T) {
+	content := `
+shared:
+  api_host: example.com
+  aws:
+    region: us-east-1
+production:
+  secret_key_base: abc
+  aws:
+    access_key_id: 123
+development:
+  secret_key_base: dev
+`
+	secrets, err := ParseSecrets(content, "production")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]any{
+		"api_host":        "example.com",
+		"secret_key_base": "abc",
+		"aws":             map[string]any{"access_key_id": 123},
+	}, secrets)
+
+	secrets, err = ParseSecrets(content, "test")
+	assert.NoError(t, err)
+	assert.Len(t, secrets, 2)
+}
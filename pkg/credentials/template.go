@@ -1,8 +1,6 @@
 package credentials
 
 import (
-	"crypto/rand"
-	"encoding/hex"
 	"fmt"
 )
 
@@ -23,13 +21,10 @@ secret_key_base: %s
 // NewCredentialsFileContent generates a credentials.yml example.
 // This function is only used in the TUI.
 func NewCredentialsFileContent() (string, error) {
-	// generate secret_key_base
-	// https://github.com/rails/rails/blob/04df9bc3d120b51447bde54caa56e9237cb8da0e/railties/lib/rails/generators/rails/credentials/credentials_generator.rb#L42
-	r := make([]byte, 32)
-	_, err := rand.Read(r)
+	secretKeyBase, err := RandomSecretKeyBase()
 	if err != nil {
-		return "", fmt.Errorf("unable to generate randomness: %w", err)
+		return "", err
 	}
 
-	return fmt.Sprintf(CredentialsFileContentTemplate, hex.EncodeToString(r)), nil
+	return fmt.Sprintf(CredentialsFileContentTemplate, secretKeyBase), nil
 }
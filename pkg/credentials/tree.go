@@ -0,0 +1,268 @@
+package credentials
+
+import (
+	"bytes"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Helpers to work with the decrypted credentials as a tree instead of a string.
+// Mappings are normalized to map[string]any and sequences to []any, so callers only need to handle these two container
+// types plus scalars.
+
+// PathSeparator separates the segments of a key path in its string form, e.g. "aws.access_key_id".
+const PathSeparator = "."
+
+// ParseContent parses decrypted credentials YAML into a tree.
+// An empty document results in an empty map.
+func ParseContent(content string) (map[string]any, error) {
+	var doc any
+	err := yaml.Unmarshal([]byte(content), &doc)
+	if err != nil {
+		return nil, newYAMLError(err)
+	}
+	if doc == nil {
+		return map[string]any{}, nil
+	}
+
+	tree, ok := normalize(doc).(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("credentials must be a YAML mapping, got %T", doc)
+	}
+	return tree, nil
+}
+
+// EncodeContent encodes a tree, or a yaml.Node document, with the indentation Rails generates.
+func EncodeContent(v any) (string, error) {
+	b := bytes.Buffer{}
+	e := yaml.NewEncoder(&b)
+	e.SetIndent(2)
+	err := e.Encode(v)
+	if err != nil {
+		return "", fmt.Errorf("encode YAML failed: %w", err)
+	}
+	err = e.Close()
+	if err != nil {
+		return "", fmt.Errorf("encode YAML failed: %w", err)
+	}
+	return b.String(), nil
+}
+
+// YAMLError is a YAML syntax or structure error with its position in the document. The YAML parser only reports
+// lines, so there is no column.
+type YAMLError struct {
+	// Line is 1-based, or 0 if the parser did not report a position.
+	Line    int
+	Message string
+}
+
+func (e *YAMLError) Error() string {
+	if e.Line == 0 {
+		return e.Message
+	}
+	return fmt.Sprintf("line %d: %s", e.Line, e.Message)
+}
+
+var yamlErrorPattern = regexp.MustCompile(`line (\d+): (.*)`)
+
+func newYAMLError(err error) *YAMLError {
+	ret := &YAMLError{Message: strings.TrimPrefix(err.Error(), "yaml: ")}
+
+	m := yamlErrorPattern.FindStringSubmatch(ret.Message)
+	if m == nil {
+		return ret
+	}
+	ret.Line, _ = strconv.Atoi(m[1])
+	ret.Message = m[2]
+	return ret
+}
+
+func normalize(node any) any {
+	switch n := node.(type) {
+	case map[string]any:
+		for k, v := range n {
+			n[k] = normalize(v)
+		}
+		return n
+	case map[any]any:
+		m := make(map[string]any, len(n))
+		for k, v := range n {
+			m[fmt.Sprint(k)] = normalize(v)
+		}
+		return m
+	case []any:
+		for i, v := range n {
+			n[i] = normalize(v)
+		}
+		return n
+	default:
+		return n
+	}
+}
+
+// Leaf is a scalar value in a credentials tree.
+type Leaf struct {
+	Path  []string
+	Value any
+}
+
+// Key returns the dotted form of the leaf path.
+func (l Leaf) Key() string {
+	return strings.Join(l.Path, PathSeparator)
+}
+
+// Leaves returns every scalar in the tree, sorted by path.
+// Sequence items use their index as the path segment.
+func Leaves(tree any) []Leaf {
+	var ret []Leaf
+	collectLeaves(tree, nil, &ret)
+	return ret
+}
+
+func collectLeaves(node any, prefix []string, ret *[]Leaf) {
+	switch n := node.(type) {
+	case map[string]any:
+		keys := make([]string, 0, len(n))
+		for k := range n {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			collectLeaves(n[k], appendPath(prefix, k), ret)
+		}
+	case []any:
+		for i, v := range n {
+			collectLeaves(v, appendPath(prefix, strconv.Itoa(i)), ret)
+		}
+	default:
+		*ret = append(*ret, Leaf{Path: prefix, Value: n})
+	}
+}
+
+// appendPath never shares the backing array with prefix, so the returned paths are safe to keep.
+func appendPath(prefix []string, segment string) []string {
+	ret := make([]string, len(prefix)+1)
+	copy(ret, prefix)
+	ret[len(prefix)] = segment
+	return ret
+}
+
+// ParsePath splits a dotted key path like "aws.access_key_id" into its segments.
+func ParsePath(p string) []string {
+	if p == "" {
+		return nil
+	}
+	return strings.Split(p, PathSeparator)
+}
+
+// HasPathPrefix reports whether path is equal to or nested under prefix.
+func HasPathPrefix(path []string, prefix []string) bool {
+	if len(prefix) > len(path) {
+		return false
+	}
+	for i := range prefix {
+		if path[i] != prefix[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Lookup returns the value at path, which may be a scalar or a subtree. Sequence items are addressed by their index.
+func Lookup(tree any, path []string) (any, bool) {
+	node := tree
+	for _, segment := range path {
+		switch n := node.(type) {
+		case map[string]any:
+			v, ok := n[segment]
+			if !ok {
+				return nil, false
+			}
+			node = v
+		case []any:
+			i, err := strconv.Atoi(segment)
+			if err != nil || i < 0 || i >= len(n) {
+				return nil, false
+			}
+			node = n[i]
+		default:
+			return nil, false
+		}
+	}
+	return node, true
+}
+
+// FilterTree keeps the subtrees under any of the prefixes, and reports whether anything was kept. A sequence that
+// only keeps some of its items becomes a mapping by their original index, so the kept items keep their paths.
+func FilterTree(tree any, prefixes [][]string) (any, bool) {
+	return filterTree(tree, nil, prefixes)
+}
+
+func filterTree(node any, path []string, prefixes [][]string) (any, bool) {
+	// only descend towards one of the prefixes
+	towards := false
+	for _, p := range prefixes {
+		if HasPathPrefix(path, p) {
+			return node, true
+		}
+		if HasPathPrefix(p, path) {
+			towards = true
+		}
+	}
+	if !towards {
+		return nil, false
+	}
+
+	switch n := node.(type) {
+	case map[string]any:
+		ret := map[string]any{}
+		for k, v := range n {
+			if c, ok := filterTree(v, appendPath(path, k), prefixes); ok {
+				ret[k] = c
+			}
+		}
+		return ret, len(ret) > 0
+	case []any:
+		ret := map[string]any{}
+		for i, v := range n {
+			if c, ok := filterTree(v, appendPath(path, strconv.Itoa(i)), prefixes); ok {
+				ret[strconv.Itoa(i)] = c
+			}
+		}
+		if len(ret) == len(n) {
+			return n, len(n) > 0
+		}
+		return ret, len(ret) > 0
+	default:
+		return nil, false
+	}
+}
+
+// FormatScalar converts a scalar leaf into the string Rails would see after interpolation.
+func FormatScalar(v any) string {
+	switch s := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return s
+	case bool:
+		return strconv.FormatBool(s)
+	case int:
+		return strconv.Itoa(s)
+	case int64:
+		return strconv.FormatInt(s, 10)
+	case uint64:
+		return strconv.FormatUint(s, 10)
+	case float64:
+		return strconv.FormatFloat(s, 'g', -1, 64)
+	case time.Time:
+		return s.Format(time.RFC3339Nano)
+	default:
+		return fmt.Sprint(s)
+	}
+}
@@ -0,0 +1,118 @@
+package credentials
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestParseContent(t *testing.T) {
+	for _, p := range testCredPairs {
+		tree, err := ParseContent(p.PlainTextData)
+		assert.NoError(t, err)
+
+		leaves := Leaves(tree)
+		assert.Len(t, leaves, 1)
+		assert.Equal(t, "secret_key_base", leaves[0].Key())
+	}
+
+	tree, err := ParseContent("")
+	assert.NoError(t, err)
+	assert.Empty(t, tree)
+
+	_, err = ParseContent("- a\n- b\n")
+	assert.Error(t, err)
+}
+
+func TestLeaves(t *testing.T) {
+	tree, err := ParseContent(`
+aws:
+  access_key_id: 123
+  enabled: true
+hosts: [a, b]
+1: numeric key
+empty:
+`)
+	assert.NoError(t, err)
+
+	var keys, values []string
+	for _, l := range Leaves(tree) {
+		keys = append(keys, l.Key())
+		values = append(values, FormatScalar(l.Value))
+	}
+	assert.Equal(t, []string{"1", "aws.access_key_id", "aws.enabled", "empty", "hosts.0", "hosts.1"}, keys)
+	assert.Equal(t, []string{"numeric key", "123", "true", "", "a", "b"}, values)
+}
+
+func TestHasPathPrefix(t *testing.T) {
+	assert.True(t, HasPathPrefix(ParsePath("aws.access_key_id"), ParsePath("aws")))
+	assert.True(t, HasPathPrefix(ParsePath("aws"), ParsePath("aws")))
+	assert.True(t, HasPathPrefix(ParsePath("aws"), nil))
+	assert.False(t, HasPathPrefix(ParsePath("aws"), ParsePath("aws.access_key_id")))
+	assert.False(t, HasPathPrefix(ParsePath("awsx.id"), ParsePath("aws")))
+}
+
+func TestYAMLError(t *testing.T) {
+	_, err := ParseContent("aws:\n  access_key_id: 123\n   secret_access_key: 345\n")
+	var yamlErr *YAMLError
+	assert.ErrorAs(t, err, &yamlErr)
+	assert.Equal(t, 3, yamlErr.Line)
+	assert.Regexp(t, `^line 3: `, yamlErr.Error())
+
+	_, err = ParseContent("a: 1\na: 2\n")
+	assert.ErrorAs(t, err, &yamlErr)
+	assert.Equal(t, 2, yamlErr.Line)
+}
+
+func TestLookup(t *testing.T) {
+	tree, err := ParseContent(`
+aws:
+  access_key_id: 123
+hosts: [a, b]
+empty:
+`)
+	assert.NoError(t, err)
+
+	v, ok := Lookup(tree, ParsePath("aws.access_key_id"))
+	assert.True(t, ok)
+	assert.Equal(t, 123, v)
+	v, ok = Lookup(tree, ParsePath("hosts.1"))
+	assert.True(t, ok)
+	assert.Equal(t, "b", v)
+	v, ok = Lookup(tree, ParsePath("aws"))
+	assert.True(t, ok)
+	assert.Equal(t, map[string]any{"access_key_id": 123}, v)
+	_, ok = Lookup(tree, ParsePath("empty"))
+	assert.True(t, ok)
+
+	for _, p := range []string{"aws.secret_access_key", "hosts.2", "hosts.x", "aws.access_key_id.x"} {
+		_, ok = Lookup(tree, ParsePath(p))
+		assert.False(t, ok, p)
+	}
+}
+
+func TestFilterTree(t *testing.T) {
+	tree, err := ParseContent(`
+aws:
+  access_key_id: 123
+  secret_access_key: 345
+smtp:
+  user_name: user
+hosts: [a, b, c]
+ports: [80, 443]
+`)
+	assert.NoError(t, err)
+
+	filtered, ok := FilterTree(tree, [][]string{ParsePath("aws.access_key_id"), ParsePath("hosts.1"), ParsePath("ports.0"), ParsePath("ports.1"), ParsePath("smtp")})
+	assert.True(t, ok)
+	assert.Equal(t, map[string]any{
+		"aws": map[string]any{"access_key_id": 123},
+		// the kept items keep their index
+		"hosts": map[string]any{"1": "b"},
+		"ports": []any{80, 443},
+		"smtp":  map[string]any{"user_name": "user"},
+	}, filtered)
+	assert.Equal(t, []Leaf{{Path: []string{"hosts", "1"}, Value: "b"}}, Leaves(map[string]any{"hosts": filtered.(map[string]any)["hosts"]}))
+
+	_, ok = FilterTree(tree, [][]string{ParsePath("aws.region")})
+	assert.False(t, ok)
+}